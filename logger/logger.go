@@ -137,7 +137,7 @@ func (l *Logger) writeBuffer() {
 
 // 使用 worker pool 处理日志消息。 处理消息时是有序的
 func (l *Logger) startWorkersOrdered(num int) {
-	workerPool := pool.NewWorkerPool(num)
+	workerPool := pool.NewWorkerPoolWithOptions(pool.Options{MaxWorker: num, QueueSize: cap(l.outputBuffer)})
 	workerPool.Start()
 
 	// 将 LogMessage 对象转换为 Job 对象，并提交到 worker pool 中处理
@@ -145,8 +145,9 @@ func (l *Logger) startWorkersOrdered(num int) {
 		for {
 			select {
 			case msg := <-l.outputBuffer:
+				// 同一个日志等级写入同一个文件，按等级串行处理以保证文件中的日志有序
 				job := &LogMessageJob{message: msg}
-				workerPool.Submit(job)
+				workerPool.SubmitKeyed(msg.level.String(), job)
 			case <-workerPool.Quit:
 				return
 			}
@@ -156,7 +157,7 @@ func (l *Logger) startWorkersOrdered(num int) {
 
 // 使用 worker pool 处理日志消息。 处理消息时是乱序的
 func (l *Logger) startWorkersDisordered(num int) {
-	workerPool := pool.NewWorkerPool(num)
+	workerPool := pool.NewWorkerPoolWithOptions(pool.Options{MaxWorker: num, QueueSize: cap(l.outputBuffer)})
 	workerPool.Start()
 
 	// 将 LogMessage 对象转换为 Job 对象，并提交到 worker pool 中处理
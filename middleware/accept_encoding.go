@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+)
+
+// acceptedEncodings 客户端接受的编码及其 q 值
+type acceptedEncodings map[string]float64
+
+// acceptEncoding 解析 Accept-Encoding 请求头，返回各编码的 q 值，没有指定 q 值的编码为 1
+func acceptEncoding(header string) acceptedEncodings {
+	codings := make(acceptedEncodings)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(param, "=")
+			if !ok || strings.TrimSpace(strings.ToLower(key)) != "q" {
+				continue
+			}
+			if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && v >= 0 && v <= 1 {
+				q = v
+			} else {
+				q = 0
+			}
+		}
+		codings[coding] = q
+	}
+
+	return codings
+}
+
+// q 返回编码的 q 值，没有明确列出的编码使用 "*" 的 q 值，都没有时为 0
+func (a acceptedEncodings) q(coding string) float64 {
+	if q, ok := a[coding]; ok {
+		return q
+	}
+	return a["*"]
+}
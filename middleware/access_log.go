@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dyouwan/utility/logger"
+	"github.com/dyouwan/utility/pipeline"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AccessLogFormat 访问日志的格式
+type AccessLogFormat int
+
+const (
+	// AccessLogCombined Apache combined 格式，末尾附加处理耗时和请求 ID
+	AccessLogCombined AccessLogFormat = iota
+	// AccessLogJSON JSON 格式，每条日志是一个 AccessLogEntry
+	AccessLogJSON
+)
+
+// AccessLogOptions 访问日志中间件的选项
+type AccessLogOptions struct {
+	Format     AccessLogFormat                 // 日志格式，默认为 AccessLogCombined
+	Include    []string                        // 需要记录的路径的正则表达式，为空时记录所有路径
+	Exclude    []string                        // 不需要记录的路径的正则表达式，优先于 Include
+	TrustProxy bool                            // 为 true 时从 X-Forwarded-For、X-Real-IP 请求头中获取客户端 IP
+	Log        func(msg string, source string) // 输出日志，为空时使用 logger.Info
+}
+
+// DefaultAccessLogOptions 默认的访问日志选项
+var DefaultAccessLogOptions = AccessLogOptions{
+	Format: AccessLogCombined,
+}
+
+// AccessLogEntry 一条访问日志
+type AccessLogEntry struct {
+	Time      time.Time     `json:"time"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Proto     string        `json:"proto"`
+	Status    int           `json:"status"`
+	Bytes     int           `json:"bytes"`
+	Duration  time.Duration `json:"duration"` // 处理耗时，单位纳秒
+	RemoteIP  string        `json:"remote_ip"`
+	UserAgent string        `json:"user_agent"`
+	Referer   string        `json:"referer"`
+	RequestID string        `json:"request_id"`
+}
+
+// AccessLog 使用默认选项创建访问日志中间件
+func AccessLog() pipeline.HandlerFunc {
+	return AccessLogWithOptions(DefaultAccessLogOptions)
+}
+
+// AccessLogWithOptions 根据选项创建访问日志中间件，记录请求的方法、路径、状态码、响应大小、耗时、客户端 IP、User-Agent 和请求 ID。
+// Include、Exclude 中的正则表达式无效时 panic
+func AccessLogWithOptions(opts AccessLogOptions) pipeline.HandlerFunc {
+	if opts.Log == nil {
+		opts.Log = logger.Info
+	}
+	include := compilePatterns(opts.Include)
+	exclude := compilePatterns(opts.Exclude)
+
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if !matchPath(r.URL.Path, include, exclude) {
+			next(rw, r)
+			return
+		}
+
+		start := time.Now()
+		w := pipeline.NewResponseWriter(rw)
+		next(w, r)
+
+		entry := AccessLogEntry{
+			Time:      start,
+			Method:    r.Method,
+			Path:      r.URL.RequestURI(),
+			Proto:     r.Proto,
+			Status:    w.Status(),
+			Bytes:     w.Size(),
+			Duration:  time.Since(start),
+			RemoteIP:  remoteIP(r, opts.TrustProxy),
+			UserAgent: r.UserAgent(),
+			Referer:   r.Referer(),
+			RequestID: RequestID(r),
+		}
+		// 没有写入响应时 net/http 返回 200
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		// 请求 ID 可能由后续的中间件设置
+		if entry.RequestID == "" {
+			entry.RequestID = w.Header().Get(RequestIDHeader)
+		}
+
+		opts.Log(formatAccessLog(opts.Format, entry), "middleware.AccessLog")
+	}
+}
+
+// formatAccessLog 按照格式输出访问日志
+func formatAccessLog(format AccessLogFormat, entry AccessLogEntry) string {
+	if format == AccessLogJSON {
+		b, _ := json.Marshal(entry)
+		return string(b)
+	}
+
+	return fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s "%s" "%s" %s %s`,
+		entry.RemoteIP,
+		entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		entry.Method, entry.Path, entry.Proto,
+		entry.Status,
+		orDash(entry.Bytes),
+		entry.Referer, entry.UserAgent,
+		entry.Duration, dash(entry.RequestID))
+}
+
+// compilePatterns 编译路径的正则表达式
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		result = append(result, regexp.MustCompile(pattern))
+	}
+	return result
+}
+
+// matchPath 判断路径是否需要记录：不匹配 exclude，并且 include 为空或者匹配 include
+func matchPath(path string, include, exclude []*regexp.Regexp) bool {
+	for _, re := range exclude {
+		if re.MatchString(path) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, re := range include {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP 返回客户端 IP，trustProxy 为 true 时优先使用代理设置的请求头
+func remoteIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(ip)
+		}
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// orDash 响应大小为 0 时按照 Apache 的格式输出 -
+func orDash(n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+// dash 空字符串输出为 -
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
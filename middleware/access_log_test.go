@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/dyouwan/utility/pipeline"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var logged []string
+	opts := AccessLogOptions{
+		Format:     AccessLogJSON,
+		Exclude:    []string{`^/health$`},
+		TrustProxy: true,
+		Log:        func(msg string, source string) { logged = append(logged, msg) },
+	}
+	p := pipeline.New(AccessLogWithOptions(opts))
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+		rw.Write([]byte("hello"))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user?id=1", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	req.Header.Set(RequestIDHeader, "req-1")
+	p.ServeHTTP(httptest.NewRecorder(), req)
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if len(logged) != 1 {
+		t.Fatalf("logged %d entries; want 1", len(logged))
+	}
+	var entry AccessLogEntry
+	if err := json.Unmarshal([]byte(logged[0]), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", logged[0], err)
+	}
+	want := AccessLogEntry{
+		Method: http.MethodPost, Path: "/api/user?id=1", Proto: "HTTP/1.1", Status: http.StatusTeapot, Bytes: 5,
+		RemoteIP: "10.0.0.1", UserAgent: "test-agent", RequestID: "req-1",
+	}
+	entry.Time, entry.Duration = want.Time, want.Duration
+	if entry != want {
+		t.Fatalf("entry = %+v; want %+v", entry, want)
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	var logged string
+	opts := AccessLogOptions{
+		Include: []string{`^/api/`},
+		Log:     func(msg string, source string) { logged = msg },
+	}
+	p := pipeline.New(AccessLogWithOptions(opts), Time())
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+	req.Header.Set("Referer", "http://example.com/")
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set(RequestIDHeader, "req-1")
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	prefix := `192.168.1.1 - - [`
+	suffix := `] "GET /api/user HTTP/1.1" 200 - "http://example.com/" "test-agent" `
+	if !strings.HasPrefix(logged, prefix) || !strings.Contains(logged, suffix) || !strings.HasSuffix(logged, " req-1") {
+		t.Fatalf("logged = %q; want Apache combined format with request ID", logged)
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"github.com/dyouwan/utility/pipeline"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// APIKey 一个 API 密钥
+type APIKey struct {
+	Name      string    // 密钥的名称，认证通过后作为 Principal 的 Subject
+	Key       string    // 密钥
+	NotBefore time.Time // 生效时间，为零值时立即生效
+	NotAfter  time.Time // 失效时间，为零值时永不失效
+}
+
+// APIKeyRing API 密钥集合。轮换密钥时可以先添加新密钥，并为旧密钥设置失效时间，在过渡期内新旧密钥都有效
+type APIKeyRing struct {
+	mu   sync.RWMutex
+	keys []APIKey
+	now  func() time.Time
+}
+
+// NewAPIKeyRing 创建一个 API 密钥集合
+func NewAPIKeyRing(keys ...APIKey) *APIKeyRing {
+	return &APIKeyRing{keys: append([]APIKey(nil), keys...), now: time.Now}
+}
+
+// StaticAPIKeys 以名称到密钥的映射创建一个永不失效的 API 密钥集合
+func StaticAPIKeys(keys map[string]string) *APIKeyRing {
+	ring := NewAPIKeyRing()
+	for name, key := range keys {
+		ring.keys = append(ring.keys, APIKey{Name: name, Key: key})
+	}
+	return ring
+}
+
+// Add 添加一个密钥
+func (k *APIKeyRing) Add(key APIKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = append(k.keys, key)
+}
+
+// Remove 删除名称为 name 的所有密钥
+func (k *APIKeyRing) Remove(name string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys := k.keys[:0]
+	for _, key := range k.keys {
+		if key.Name != name {
+			keys = append(keys, key)
+		}
+	}
+	k.keys = keys
+}
+
+// Set 整体替换所有的密钥
+func (k *APIKeyRing) Set(keys ...APIKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = append([]APIKey(nil), keys...)
+}
+
+// Lookup 查找当前有效的密钥，返回密钥的名称。比较密钥时使用常量时间比较
+func (k *APIKeyRing) Lookup(key string) (string, bool) {
+	now := k.now()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	name, found := "", false
+	for _, candidate := range k.keys {
+		if !candidate.NotBefore.IsZero() && now.Before(candidate.NotBefore) {
+			continue
+		}
+		if !candidate.NotAfter.IsZero() && !now.Before(candidate.NotAfter) {
+			continue
+		}
+		// 比较所有密钥，不提前返回
+		if subtle.ConstantTimeCompare([]byte(candidate.Key), []byte(key)) == 1 && !found {
+			name, found = candidate.Name, true
+		}
+	}
+	return name, found
+}
+
+// APIKeyOptions APIKeyAuth 中间件的选项
+type APIKeyOptions struct {
+	Keys      *APIKeyRing // 有效的密钥
+	Header    string      // 携带密钥的请求头，默认为 X-API-Key
+	Authorize Authorizer  // 认证通过后检查权限，返回 false 时响应 403，可选
+}
+
+// APIKeyAuth 根据选项创建 API 密钥认证中间件。
+// 认证通过后 Principal 的 Subject 为密钥的名称；缺少密钥或密钥无效时响应 401
+func APIKeyAuth(opts APIKeyOptions) pipeline.HandlerFunc {
+	if opts.Keys == nil {
+		panic("apikey: key ring cannot be nil")
+	}
+	if opts.Header == "" {
+		opts.Header = "X-API-Key"
+	}
+
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		key := r.Header.Get(opts.Header)
+		if key == "" {
+			unauthorized(rw, "", "missing API key")
+			return
+		}
+
+		name, ok := opts.Keys.Lookup(key)
+		if !ok {
+			unauthorized(rw, "", "invalid API key")
+			return
+		}
+
+		authenticate(rw, r, next, &Principal{Subject: name, Method: "apikey"}, opts.Authorize)
+	}
+}
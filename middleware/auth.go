@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"github.com/dyouwan/utility/response"
+	"net/http"
+)
+
+// Principal 通过认证的调用方
+type Principal struct {
+	Subject string                 // 调用方标识，JWT 为 sub，API key 为密钥的名称
+	Method  string                 // 认证方式，"jwt" 或 "apikey"
+	Claims  map[string]interface{} // JWT 的 claims，API key 认证时为空
+}
+
+// principalKey Principal 在 context 中的键
+type principalKey struct{}
+
+// PrincipalFrom 返回认证中间件放入请求 context 中的调用方，没有通过认证时返回 false
+func PrincipalFrom(r *http.Request) (*Principal, bool) {
+	p, ok := r.Context().Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// withPrincipal 返回 context 中带有调用方的请求
+func withPrincipal(r *http.Request, p *Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, p))
+}
+
+// Authorizer 判断通过认证的调用方是否有权限访问请求的资源
+type Authorizer func(p *Principal, r *http.Request) bool
+
+// authenticate 认证通过后检查权限，无权限时返回 403，否则将调用方放入请求 context 并调用 next
+func authenticate(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc, p *Principal, authorize Authorizer) {
+	if authorize != nil && !authorize(p, r) {
+		response.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	next(rw, withPrincipal(r, p))
+}
+
+// unauthorized 返回 401 响应，challenge 为 WWW-Authenticate 响应头的值
+func unauthorized(rw http.ResponseWriter, challenge string, message string) {
+	if challenge != "" {
+		rw.Header().Set("WWW-Authenticate", challenge)
+	}
+	response.Error(rw, message, http.StatusUnauthorized)
+}
@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/dyouwan/utility/pipeline"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// signJWT 签发一个测试用的 JWT，key 为 []byte 时使用 HS256，为 *rsa.PrivateKey 时使用 RS256
+func signJWT(t *testing.T, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	alg := "HS256"
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		alg = "RS256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("SignPKCS1v15() = %v", err)
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// serveAuth 以认证中间件处理请求，返回响应和认证通过的调用方
+func serveAuth(handler pipeline.Handler, header, value string) (*httptest.ResponseRecorder, *Principal) {
+	var principal *Principal
+	p := pipeline.New(handler)
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) { principal, _ = PrincipalFrom(r) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if value != "" {
+		req.Header.Set(header, value)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	return rec, principal
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("secret")
+	keys := NewKeySet()
+	keys.AddHMAC("hs", secret)
+
+	now := time.Unix(1700000000, 0)
+	auth := JWT(JWTOptions{
+		Keys:      keys,
+		Issuer:    "issuer",
+		Audience:  "api",
+		ClockSkew: time.Minute,
+		Now:       func() time.Time { return now },
+		Authorize: func(p *Principal, r *http.Request) bool { return p.Claims["role"] == "admin" },
+	})
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"sub": "alice", "iss": "issuer", "aud": []string{"web", "api"}, "exp": now.Unix() + 60, "role": "admin"}
+		for k, v := range changes {
+			c[k] = v
+		}
+		return c
+	}
+
+	rec, principal := serveAuth(auth, "Authorization", "Bearer "+signJWT(t, "hs", secret, claims(nil)))
+	if rec.Code != http.StatusOK || principal == nil || principal.Subject != "alice" || principal.Method != "jwt" {
+		t.Fatalf("valid token: code = %d, principal = %+v", rec.Code, principal)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		code  int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"malformed", "Bearer abc", http.StatusUnauthorized},
+		{"wrong secret", "Bearer " + signJWT(t, "hs", []byte("other"), claims(nil)), http.StatusUnauthorized},
+		{"expired", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"exp": now.Unix() - 120})), http.StatusUnauthorized},
+		{"within skew", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"exp": now.Unix() - 30})), http.StatusOK},
+		{"not yet valid", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"nbf": now.Unix() + 120})), http.StatusUnauthorized},
+		{"string exp", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"exp": fmt.Sprint(now.Unix() - 120)})), http.StatusUnauthorized},
+		{"string nbf", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"nbf": fmt.Sprint(now.Unix() + 120)})), http.StatusUnauthorized},
+		{"null exp", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"exp": nil})), http.StatusUnauthorized},
+		{"far future nbf", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"nbf": 1e19})), http.StatusUnauthorized},
+		{"far future exp", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"exp": 1e19})), http.StatusOK},
+		{"wrong issuer", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"iss": "other"})), http.StatusUnauthorized},
+		{"wrong audience", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"aud": "web"})), http.StatusUnauthorized},
+		{"forbidden", "Bearer " + signJWT(t, "hs", secret, claims(map[string]interface{}{"role": "user"})), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rec, _ := serveAuth(auth, "Authorization", tt.token)
+		if rec.Code != tt.code {
+			t.Fatalf("%s: code = %d; want %d (%s)", tt.name, rec.Code, tt.code, rec.Body.String())
+		}
+		if tt.code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("%s: missing WWW-Authenticate header", tt.name)
+		}
+	}
+
+	// 默认拒绝没有 exp 的 JWT，AllowMissingExp 时接受
+	noExp := claims(nil)
+	delete(noExp, "exp")
+	token := "Bearer " + signJWT(t, "hs", secret, noExp)
+	for _, tt := range []struct {
+		name string
+		auth pipeline.HandlerFunc
+		code int
+	}{
+		{"required exp", JWT(JWTOptions{Keys: keys, Now: func() time.Time { return now }}), http.StatusUnauthorized},
+		{"allow missing exp", JWT(JWTOptions{Keys: keys, AllowMissingExp: true, Now: func() time.Time { return now }}), http.StatusOK},
+	} {
+		if rec, _ := serveAuth(tt.auth, "Authorization", token); rec.Code != tt.code {
+			t.Fatalf("%s: code = %d; want %d", tt.name, rec.Code, tt.code)
+		}
+	}
+}
+
+func TestJWTKeySetFile(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	file, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kid": "rs", "alg": "RS256", "pem": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))},
+		{"kid": "hs", "alg": "HS256", "secret": "secret"},
+	}})
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, file, 0600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+
+	keys, err := LoadKeySet(path)
+	if err != nil {
+		t.Fatalf("LoadKeySet() = %v", err)
+	}
+	auth := JWT(JWTOptions{Keys: keys})
+
+	claims := map[string]interface{}{"sub": "svc", "exp": time.Now().Add(time.Hour).Unix()}
+	if rec, principal := serveAuth(auth, "Authorization", "Bearer "+signJWT(t, "rs", private, claims)); rec.Code != http.StatusOK || principal.Subject != "svc" {
+		t.Fatalf("RS256 token: code = %d, principal = %+v", rec.Code, principal)
+	}
+	// 使用 RSA 公钥作为 HMAC 密钥伪造的签名不被接受
+	if rec, _ := serveAuth(auth, "Authorization", "Bearer "+signJWT(t, "rs", der, claims)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("algorithm confusion: code = %d; want 401", rec.Code)
+	}
+
+	// 空的 HS256 密钥不被接受
+	file, _ = json.Marshal(map[string]interface{}{"keys": []map[string]string{{"kid": "hs", "alg": "HS256", "secret": ""}}})
+	if err := os.WriteFile(path, file, 0600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	if err := keys.Load(path); err == nil {
+		t.Fatal("Load() with empty secret = nil; want error")
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ring := StaticAPIKeys(map[string]string{"static": "key-static"})
+	ring.now = func() time.Time { return now }
+	ring.Add(APIKey{Name: "old", Key: "key-old", NotAfter: now.Add(-time.Second)})
+	ring.Add(APIKey{Name: "new", Key: "key-new", NotBefore: now.Add(-time.Hour)})
+	auth := APIKeyAuth(APIKeyOptions{Keys: ring})
+
+	tests := []struct {
+		key     string
+		code    int
+		subject string
+	}{
+		{"key-static", http.StatusOK, "static"},
+		{"key-new", http.StatusOK, "new"},
+		{"key-old", http.StatusUnauthorized, ""},
+		{"wrong", http.StatusUnauthorized, ""},
+		{"", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		rec, principal := serveAuth(auth, "X-API-Key", tt.key)
+		if rec.Code != tt.code {
+			t.Fatalf("key %q: code = %d; want %d", tt.key, rec.Code, tt.code)
+		}
+		if tt.code == http.StatusOK && (principal == nil || principal.Subject != tt.subject || principal.Method != "apikey") {
+			t.Fatalf("key %q: principal = %+v; want subject %q", tt.key, principal, tt.subject)
+		}
+	}
+}
+
+func TestAuthRouteMiddlewares(t *testing.T) {
+	auth := APIKeyAuth(APIKeyOptions{Keys: StaticAPIKeys(map[string]string{"svc": "key"})})
+	ok := func(rw http.ResponseWriter, r *http.Request) {}
+
+	router := mux.NewRouter()
+	pipeline.AddRoutes([]pipeline.Route{
+		{Name: "public", Method: http.MethodGet, Pattern: "/public", HandlerFunc: ok},
+		{Name: "private", Method: http.MethodGet, Pattern: "/private", HandlerFunc: ok, Middlewares: []pipeline.Handler{auth}},
+	}, router)
+
+	for path, code := range map[string]int{"/public": http.StatusOK, "/private": http.StatusUnauthorized} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != code {
+			t.Fatalf("%s: code = %d; want %d", path, rec.Code, code)
+		}
+	}
+}
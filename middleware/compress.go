@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"github.com/dyouwan/utility/pipeline"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// DefaultEncodingPreference q 值相同时优先选择的编码，靠前的优先
+var DefaultEncodingPreference = []string{"zstd", "br", "gzip", "deflate"}
+
+// CompressOptions Compress 中间件的选项
+type CompressOptions struct {
+	MinSize      int                       // 响应体达到该大小（字节）才压缩，默认 1024
+	ContentTypes []string                  // 需要压缩的 Content-Type 前缀，默认为 DefaultCompressibleTypes
+	Encoders     map[string]EncoderFactory // 可用的编码，为空时使用 RegisterEncoder 注册的编码
+	Preference   []string                  // q 值相同时优先选择的编码，默认为 DefaultEncodingPreference
+}
+
+var (
+	encodersMu sync.RWMutex
+	// encoders 注册的编码，内置 gzip 和 deflate
+	encoders = map[string]EncoderFactory{
+		"gzip":    GzipEncoder(gzip.DefaultCompression),
+		"deflate": DeflateEncoder(flate.DefaultCompression),
+	}
+)
+
+// RegisterEncoder 注册一种编码，name 为 Content-Encoding 的值，例如 br、zstd。
+// 已经存在的编码会被替换，只影响之后创建的 Compress 中间件
+func RegisterEncoder(name string, factory EncoderFactory) {
+	if factory == nil {
+		panic("encoder factory cannot be nil")
+	}
+
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[name] = factory
+}
+
+// DeflateEncoder 返回以 level 压缩的 deflate 编码器的 EncoderFactory，level 无效时 panic
+func DeflateEncoder(level int) EncoderFactory {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		panic(fmt.Sprintf("flate: invalid compression level: %d", level))
+	}
+	return func(w io.Writer) Encoder {
+		fw, _ := flate.NewWriter(w, level)
+		return fw
+	}
+}
+
+// Compress 使用注册的编码创建压缩中间件，参见 CompressWithOptions
+func Compress() pipeline.HandlerFunc {
+	return CompressWithOptions(CompressOptions{})
+}
+
+// CompressWithOptions 根据选项创建压缩中间件：按照 Accept-Encoding 中的 q 值选择客户端最希望使用的编码，
+// q 值相同时按照 Preference 选择。是否压缩的规则与 Gzip 相同
+func CompressWithOptions(opts CompressOptions) pipeline.HandlerFunc {
+	factories := opts.Encoders
+	if factories == nil {
+		encodersMu.RLock()
+		factories = make(map[string]EncoderFactory, len(encoders))
+		for name, factory := range encoders {
+			factories[name] = factory
+		}
+		encodersMu.RUnlock()
+	}
+	preference := opts.Preference
+	if preference == nil {
+		preference = DefaultEncodingPreference
+	}
+
+	policy := newCompressPolicy(opts.MinSize, opts.ContentTypes)
+	names := sortEncodings(factories, preference)
+	pools := make(map[string]*encoderPool, len(factories))
+	for name, factory := range factories {
+		pools[name] = &encoderPool{factory: factory}
+	}
+
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if !eligible(r) {
+			next(rw, r)
+			return
+		}
+
+		// 响应内容随 Accept-Encoding 变化，缓存需要区分
+		addVary(rw.Header(), "Accept-Encoding")
+
+		encoding := negotiate(acceptEncoding(r.Header.Get("Accept-Encoding")), names)
+		if encoding == "" {
+			next(rw, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: rw,
+			policy:         policy,
+			encoding:       encoding,
+			encoders:       pools[encoding],
+		}
+		defer cw.Close()
+		next(cw, r)
+	}
+}
+
+// negotiate 返回 q 值最大的编码，names 按照优先顺序排列，客户端不接受任何编码时返回空字符串
+func negotiate(accepted acceptedEncodings, names []string) string {
+	best, bestQ := "", 0.0
+	for _, name := range names {
+		if q := accepted.q(name); q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// sortEncodings 按照 preference 排列编码，不在 preference 中的编码按名称排在最后
+func sortEncodings(factories map[string]EncoderFactory, preference []string) []string {
+	rank := func(name string) int {
+		for i, preferred := range preference {
+			if preferred == name {
+				return i
+			}
+		}
+		return len(preference)
+	}
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := rank(names[i]), rank(names[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
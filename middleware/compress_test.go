@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"compress/flate"
+	"github.com/dyouwan/utility/pipeline"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	names := []string{"br", "gzip", "deflate"}
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"deflate, gzip, br", "br"},
+		{"br;q=0, gzip;q=0.8, *;q=0.1", "gzip"},
+		{"*", "br"},
+		{"identity", ""},
+		{"gzip;q=0", ""},
+		{"GZIP ; Q=0.9 , deflate;q=0.5", "gzip"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiate(acceptEncoding(tt.acceptEncoding), names); got != tt.want {
+			t.Fatalf("negotiate(%q) = %q; want %q", tt.acceptEncoding, got, tt.want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("hello world ", 200)
+	// 用 deflate 模拟可插拔的 br 编码
+	p := pipeline.New(CompressWithOptions(CompressOptions{
+		Encoders: map[string]EncoderFactory{
+			"br":      DeflateEncoder(flate.BestSpeed),
+			"deflate": DeflateEncoder(flate.DefaultCompression),
+		},
+	}))
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(body))
+	})
+
+	for _, encoding := range []string{"br", "deflate"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip, "+encoding)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+
+		if rec.Header().Get("Content-Encoding") != encoding {
+			t.Fatalf("Content-Encoding = %q; want %q", rec.Header().Get("Content-Encoding"), encoding)
+		}
+		data, err := io.ReadAll(flate.NewReader(rec.Body))
+		if err != nil || string(data) != body {
+			t.Fatalf("body = %q, %v; want original body", data, err)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressibleTypes 默认压缩的 Content-Type 前缀，图片、视频、压缩包等已经压缩过的内容不再压缩
+var DefaultCompressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/x-javascript",
+	"application/xml",
+	"application/xhtml+xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"image/svg+xml",
+}
+
+// defaultMinSize 默认压缩的最小响应体大小
+const defaultMinSize = 1024
+
+// Encoder 压缩编码器，compress/gzip、compress/flate 以及常见的 brotli、zstd 实现都满足该接口
+type Encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// EncoderFactory 创建输出到 w 的编码器
+type EncoderFactory func(w io.Writer) Encoder
+
+// encoderPool 复用同一种编码器
+type encoderPool struct {
+	factory EncoderFactory
+	pool    sync.Pool
+}
+
+// acquire 获取一个输出到 w 的编码器
+func (p *encoderPool) acquire(w io.Writer) Encoder {
+	if enc, ok := p.pool.Get().(Encoder); ok {
+		enc.Reset(w)
+		return enc
+	}
+	return p.factory(w)
+}
+
+// release 归还编码器
+func (p *encoderPool) release(enc Encoder) {
+	p.pool.Put(enc)
+}
+
+// compressPolicy 决定响应是否需要压缩
+type compressPolicy struct {
+	minSize      int
+	contentTypes []string
+}
+
+// newCompressPolicy 创建压缩策略，minSize 小于等于 0 时使用默认值，contentTypes 为空时使用 DefaultCompressibleTypes
+func newCompressPolicy(minSize int, contentTypes []string) compressPolicy {
+	if minSize <= 0 {
+		minSize = defaultMinSize
+	}
+	if len(contentTypes) == 0 {
+		contentTypes = DefaultCompressibleTypes
+	}
+	return compressPolicy{minSize: minSize, contentTypes: contentTypes}
+}
+
+// compressible 判断 Content-Type 是否在允许压缩的列表中
+func (p compressPolicy) compressible(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, prefix := range p.contentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// eligible 判断请求的响应是否可能被压缩
+func eligible(r *http.Request) bool {
+	// HEAD 请求没有响应体，Range 请求的偏移量基于未压缩的内容
+	return r.Method != http.MethodHead && r.Header.Get("Range") == ""
+}
+
+// compressWriter 压缩响应体的 ResponseWriter。
+// 响应体达到最小大小之前先缓存在内存中，之后根据状态码、Content-Type 和 Content-Encoding 决定是否压缩。
+// 实现了 pipeline.ResponseWriter 接口，缓存期间后续的中间件也能知道响应已经写入
+type compressWriter struct {
+	http.ResponseWriter
+	policy   compressPolicy
+	encoding string       // Content-Encoding 的值
+	encoders *encoderPool // 编码器池
+
+	status  int
+	size    int // 处理程序写入的未压缩的响应体字节数
+	buf     []byte
+	decided bool    // 是否已经决定了是否压缩，决定之后响应头已经写入
+	enc     Encoder // 压缩时使用的编码器
+}
+
+// WriteHeader 记录状态码，没有响应体的状态码以及已经设置了 Content-Encoding 的响应直接写入
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	// 1xx 状态码之后还会有最终的响应
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.status = status
+	if !bodyAllowed(status) || w.Header().Get("Content-Encoding") != "" {
+		w.decide(false)
+	}
+}
+
+// Write 写入响应体，达到最小大小后开始压缩
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		var n int
+		var err error
+		if w.enc != nil {
+			n, err = w.enc.Write(b)
+		} else {
+			n, err = w.ResponseWriter.Write(b)
+		}
+		w.size += n
+		return n, err
+	}
+
+	w.size += len(b)
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.policy.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide 决定是否压缩并写入响应头和已经缓存的响应体
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if bodyAllowed(w.status) && h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// 压缩后 net/http 无法再根据内容推断 Content-Type
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if compress && h.Get("Content-Encoding") == "" && w.policy.compressible(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		w.enc = w.encoders.acquire(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Status 实现 pipeline.ResponseWriter 接口
+func (w *compressWriter) Status() int {
+	return w.status
+}
+
+// Size 实现 pipeline.ResponseWriter 接口，返回未压缩的响应体字节数
+func (w *compressWriter) Size() int {
+	return w.size
+}
+
+// Written 实现 pipeline.ResponseWriter 接口，状态码确定后即视为已经写入，即使响应头还缓存着没有发送
+func (w *compressWriter) Written() bool {
+	return w.status != 0
+}
+
+// Close 结束响应：没有达到最小大小的响应体不压缩直接写入，压缩时写入压缩数据的结尾并归还编码器
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		// 处理程序没有写入任何内容时保持 net/http 的默认行为
+		if w.status == 0 {
+			return nil
+		}
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	w.encoders.release(w.enc)
+	w.enc = nil
+	return err
+}
+
+// Flush 实现 http.Flusher 接口，流式响应在第一次 Flush 时即决定是否压缩
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.decide(bodyAllowed(w.status))
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack 实现 http.Hijacker 接口
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap 返回被包装的 ResponseWriter，供 http.ResponseController 使用
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// bodyAllowed 判断状态码是否允许有响应体
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified && status >= 200
+}
+
+// addVary 在 Vary 响应头中添加 fields，已经存在的字段不重复添加
+func addVary(h http.Header, fields ...string) {
+	existing := make(map[string]bool)
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			existing[strings.ToLower(strings.TrimSpace(field))] = true
+		}
+	}
+	if existing["*"] {
+		return
+	}
+
+	for _, field := range fields {
+		if !existing[strings.ToLower(field)] {
+			h.Add("Vary", field)
+		}
+	}
+}
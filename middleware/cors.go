@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"github.com/dyouwan/utility/pipeline"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions CORS 中间件的选项
+type CORSOptions struct {
+	// AllowedOrigins 允许的来源，支持精确匹配、"*" 和包含一个 * 的通配符（例如 https://*.example.com）
+	AllowedOrigins []string
+	// AllowedOriginPatterns 允许的来源的正则表达式，需要匹配整个来源（自动加上 ^ 和 $）
+	AllowedOriginPatterns []string
+	// AllowOriginFunc 判断来源是否允许，与 AllowedOrigins、AllowedOriginPatterns 任一匹配即允许
+	AllowOriginFunc func(origin string) bool
+	// AllowedMethods 允许的方法，默认为 GET、HEAD、POST
+	AllowedMethods []string
+	// AllowedHeaders 允许的请求头，"*" 表示允许所有请求头，默认为 Accept、Content-Type、X-Requested-With
+	AllowedHeaders []string
+	// ExposedHeaders 允许浏览器读取的响应头
+	ExposedHeaders []string
+	// AllowCredentials 是否允许携带 Cookie 等凭据，不能与 AllowedOrigins 中的 "*" 同时使用
+	AllowCredentials bool
+	// MaxAge 预检请求结果的缓存时间，单位秒，为 0 时不设置
+	MaxAge int
+}
+
+// cors 编译后的 CORS 选项
+type cors struct {
+	allowAll       bool
+	origins        []string
+	wildcards      [][2]string // 通配符的前缀和后缀
+	patterns       []*regexp.Regexp
+	originFunc     func(origin string) bool
+	methods        []string
+	headers        []string
+	allowAllHeader bool
+	exposedHeaders string
+	credentials    bool
+	maxAge         string
+}
+
+// CORS 根据选项创建跨域资源共享中间件。
+// 预检请求（带有 Access-Control-Request-Method 的 OPTIONS 请求）直接以 204 响应，不再调用后续的处理程序。
+// 返回的中间件带有 pipeline.Preflight 标记，用于路由或分组时会同时注册 OPTIONS 方法以接收预检请求。
+// 正则表达式无效或者允许所有来源的同时允许携带凭据时 panic
+func CORS(opts CORSOptions) pipeline.Handler {
+	c := &cors{
+		originFunc:  opts.AllowOriginFunc,
+		methods:     []string{http.MethodGet, http.MethodHead, http.MethodPost},
+		headers:     []string{"Accept", "Content-Type", "X-Requested-With"},
+		credentials: opts.AllowCredentials,
+	}
+
+	for _, origin := range opts.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			c.allowAll = true
+		case strings.Count(origin, "*") == 1:
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			c.wildcards = append(c.wildcards, [2]string{prefix, suffix})
+		default:
+			c.origins = append(c.origins, origin)
+		}
+	}
+	if c.allowAll && c.credentials {
+		panic(`cors: AllowedOrigins "*" cannot be used with AllowCredentials`)
+	}
+
+	// 正则表达式需要匹配整个来源，避免 https://app.example.com.evil.com 之类的来源通过检查
+	patterns := make([]string, 0, len(opts.AllowedOriginPatterns))
+	for _, pattern := range opts.AllowedOriginPatterns {
+		patterns = append(patterns, "^(?:"+pattern+")$")
+	}
+	c.patterns = compilePatterns(patterns)
+
+	if len(opts.AllowedMethods) > 0 {
+		c.methods = c.methods[:0]
+		for _, method := range opts.AllowedMethods {
+			c.methods = append(c.methods, strings.ToUpper(method))
+		}
+	}
+	if len(opts.AllowedHeaders) > 0 {
+		c.headers = c.headers[:0]
+		for _, header := range opts.AllowedHeaders {
+			if header == "*" {
+				c.allowAllHeader = true
+			}
+			c.headers = append(c.headers, http.CanonicalHeaderKey(header))
+		}
+	}
+	c.exposedHeaders = strings.Join(opts.ExposedHeaders, ", ")
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(opts.MaxAge)
+	}
+
+	return pipeline.Preflight(pipeline.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(rw, r)
+			return
+		}
+
+		c.actual(rw, r)
+		next(rw, r)
+	}))
+}
+
+// preflight 响应预检请求，来源、方法或请求头不允许时不设置 CORS 响应头，由浏览器拒绝跨域请求
+func (c *cors) preflight(rw http.ResponseWriter, r *http.Request) {
+	h := rw.Header()
+	addVary(h, "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+	defer rw.WriteHeader(http.StatusNoContent)
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !c.allowOrigin(origin) {
+		return
+	}
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !c.allowMethod(method) {
+		return
+	}
+	headers := requestedHeaders(r.Header.Get("Access-Control-Request-Headers"))
+	if !c.allowHeaders(headers) {
+		return
+	}
+
+	c.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", method)
+	if len(headers) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+}
+
+// actual 为跨域的实际请求设置 CORS 响应头
+func (c *cors) actual(rw http.ResponseWriter, r *http.Request) {
+	h := rw.Header()
+	origin := r.Header.Get("Origin")
+	if !c.allowAll {
+		addVary(h, "Origin")
+	}
+	if origin == "" || !c.allowOrigin(origin) {
+		return
+	}
+
+	c.setOrigin(h, origin)
+	if c.exposedHeaders != "" {
+		h.Set("Access-Control-Expose-Headers", c.exposedHeaders)
+	}
+}
+
+// setOrigin 设置允许的来源和凭据响应头
+func (c *cors) setOrigin(h http.Header, origin string) {
+	if c.allowAll {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// allowOrigin 判断来源是否允许
+func (c *cors) allowOrigin(origin string) bool {
+	if c.allowAll {
+		return true
+	}
+
+	lower := strings.ToLower(origin)
+	for _, allowed := range c.origins {
+		if lower == allowed {
+			return true
+		}
+	}
+	for _, w := range c.wildcards {
+		if len(lower) >= len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+			return true
+		}
+	}
+	for _, re := range c.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return c.originFunc != nil && c.originFunc(origin)
+}
+
+// allowMethod 判断方法是否允许
+func (c *cors) allowMethod(method string) bool {
+	for _, allowed := range c.methods {
+		if method == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders 判断请求头是否都允许
+func (c *cors) allowHeaders(headers []string) bool {
+	if c.allowAllHeader {
+		return true
+	}
+
+next:
+	for _, header := range headers {
+		for _, allowed := range c.headers {
+			if header == allowed {
+				continue next
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// requestedHeaders 解析 Access-Control-Request-Headers 请求头
+func requestedHeaders(value string) []string {
+	var headers []string
+	for _, header := range strings.Split(value, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, http.CanonicalHeaderKey(header))
+		}
+	}
+	return headers
+}
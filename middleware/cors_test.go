@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"github.com/dyouwan/utility/pipeline"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveCORS 以 CORS 中间件处理请求，返回响应和是否调用了后续的处理程序
+func serveCORS(opts CORSOptions, req *http.Request) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	p := pipeline.New(CORS(opts))
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) { reached = true })
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	return rec, reached
+}
+
+func preflightRequest(origin, method, headers string) *http.Request {
+	req := httptest.NewRequest(http.MethodOptions, "/api/user", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return req
+}
+
+func TestCORSOrigins(t *testing.T) {
+	opts := CORSOptions{
+		AllowedOrigins:        []string{"https://app.example.com", "https://*.example.org"},
+		AllowedOriginPatterns: []string{`https://[a-z]+\.example\.net`},
+		AllowOriginFunc:       func(origin string) bool { return origin == "http://localhost:8080" },
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://api.example.net", true},
+		{"https://api2.example.net", false},
+		{"https://api.example.net.evil.com", false},
+		{"https://evil.com/https://api.example.net", false},
+		{"http://localhost:8080", true},
+		{"https://evil.com", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", tt.origin)
+		rec, reached := serveCORS(opts, req)
+
+		if !reached {
+			t.Fatalf("%s: next not called for actual request", tt.origin)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); (got == tt.origin) != tt.allowed {
+			t.Fatalf("%s: Access-Control-Allow-Origin = %q; want allowed = %v", tt.origin, got, tt.allowed)
+		}
+		if rec.Header().Get("Vary") != "Origin" {
+			t.Fatalf("%s: Vary = %q; want Origin", tt.origin, rec.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	opts := CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "put"},
+		AllowedHeaders:   []string{"Content-Type", "authorization"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+
+	rec, reached := serveCORS(opts, preflightRequest("https://app.example.com", "PUT", "content-type, Authorization"))
+	if reached || rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight: code = %d, reached = %v; want 204 without calling next", rec.Code, reached)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "PUT",
+		"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+		"Access-Control-Max-Age":           "600",
+	}
+	for key, value := range want {
+		if got := rec.Header().Get(key); got != value {
+			t.Fatalf("%s = %q; want %q", key, got, value)
+		}
+	}
+	if vary := strings.Join(rec.Header().Values("Vary"), ","); !strings.Contains(vary, "Access-Control-Request-Headers") {
+		t.Fatalf("Vary = %q; want preflight request headers", vary)
+	}
+
+	// 不允许的方法和请求头
+	for _, req := range []*http.Request{
+		preflightRequest("https://app.example.com", "DELETE", ""),
+		preflightRequest("https://app.example.com", "GET", "X-Custom"),
+	} {
+		rec, reached := serveCORS(opts, req)
+		if reached || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("disallowed preflight: reached = %v, headers = %v", reached, rec.Header())
+		}
+	}
+
+	// 实际请求返回 Expose-Headers
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec, _ = serveCORS(opts, req)
+	if rec.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+		t.Fatalf("Access-Control-Expose-Headers = %q", rec.Header().Get("Access-Control-Expose-Headers"))
+	}
+}
+
+func TestCORSAllowAllWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal(`CORS() with "*" and AllowCredentials did not panic`)
+		}
+	}()
+	CORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestCORSRouter(t *testing.T) {
+	opts := CORSOptions{AllowedOrigins: []string{"https://app.example.com"}, AllowedMethods: []string{"GET", "PUT"}}
+	ok := func(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte("ok")) }
+
+	router := mux.NewRouter()
+	pipeline.AddRoutes([]pipeline.Route{
+		{Name: "user", Method: http.MethodPut, Pattern: "/user", HandlerFunc: ok, Middlewares: []pipeline.Handler{CORS(opts)}},
+		{Name: "health", Method: http.MethodGet, Pattern: "/health", HandlerFunc: ok},
+	}, router)
+	api := pipeline.Group(router, "/api", CORS(opts))
+	api.AddRoutes([]pipeline.Route{{Name: "order", Method: http.MethodPut, Pattern: "/order", HandlerFunc: ok}})
+
+	// 路由和分组的 CORS 中间件都能收到经过 mux 方法匹配的预检请求
+	for _, path := range []string{"/user", "/api/order"} {
+		req := preflightRequest("https://app.example.com", "PUT", "")
+		req.URL.Path = path
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+			t.Fatalf("%s preflight: code = %d, headers = %v; want 204 with CORS headers", path, rec.Code, rec.Header())
+		}
+
+		req = httptest.NewRequest(http.MethodPut, path, nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Body.String() != "ok" || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+			t.Fatalf("%s: body = %q, headers = %v; want ok with CORS headers", path, rec.Body.String(), rec.Header())
+		}
+	}
+
+	// 没有使用 CORS 的路由不接受 OPTIONS
+	req := preflightRequest("https://app.example.com", "GET", "")
+	req.URL.Path = "/health"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("/health preflight: code = %d; want 405", rec.Code)
+	}
+}
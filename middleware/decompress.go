@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"github.com/dyouwan/utility/pipeline"
+	"github.com/dyouwan/utility/response"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DecompressOptions Decompress 中间件的选项
+type DecompressOptions struct {
+	MaxSize int64 // 解压后请求体的最大字节数，超过时返回 413，默认 10MB
+}
+
+// DefaultDecompressOptions 默认的 Decompress 选项
+var DefaultDecompressOptions = DecompressOptions{
+	MaxSize: 10 << 20,
+}
+
+var (
+	// errBodyTooLarge 解压后的请求体超过最大大小
+	errBodyTooLarge = errors.New("request body too large")
+	// errUnsupportedEncoding 不支持的 Content-Encoding
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+)
+
+// Decompress 使用默认选项创建请求体解压中间件，参见 DecompressWithOptions
+func Decompress() pipeline.HandlerFunc {
+	return DecompressWithOptions(DefaultDecompressOptions)
+}
+
+// DecompressWithOptions 根据选项创建请求体解压中间件：按照 Content-Encoding 解压 gzip、deflate 编码的请求体，
+// 后续的处理程序读取到的是解压后的内容。
+// 为了在超过最大大小时返回 413，请求体会在调用后续处理程序之前完整解压到内存中。
+// 不支持的编码返回 415，请求体无法解压返回 400
+func DecompressWithOptions(opts DecompressOptions) pipeline.HandlerFunc {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultDecompressOptions.MaxSize
+	}
+
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+			next(rw, r)
+			return
+		}
+
+		body, err := decompressBody(r.Body, encoding, opts.MaxSize)
+		r.Body.Close()
+		switch {
+		case err == errUnsupportedEncoding:
+			response.Error(rw, fmt.Sprintf("unsupported content encoding: %s", encoding), http.StatusUnsupportedMediaType)
+			return
+		case err == errBodyTooLarge:
+			response.Error(rw, errBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			response.Error(rw, fmt.Sprintf("invalid %s request body: %v", encoding, err), http.StatusBadRequest)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		next(rw, r)
+	}
+}
+
+// decompressBody 解压请求体，解压后的内容超过 maxSize 时返回 errBodyTooLarge
+func decompressBody(body io.Reader, encoding string, maxSize int64) ([]byte, error) {
+	var reader io.Reader
+	switch encoding {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	case "deflate":
+		fr, err := newDeflateReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer fr.Close()
+		reader = fr
+	default:
+		return nil, errUnsupportedEncoding
+	}
+
+	// 多读一个字节以判断是否超过最大大小
+	data, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, errBodyTooLarge
+	}
+	return data, nil
+}
+
+// newDeflateReader 创建 deflate 请求体的读取器。
+// HTTP 规定 deflate 是 zlib 格式，但有些客户端发送的是没有 zlib 头的原始 deflate 数据，两种都支持
+func newDeflateReader(body io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(body)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	// zlib 头：压缩方法为 8（deflate），并且前两个字节组成的数是 31 的倍数
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
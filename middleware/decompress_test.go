@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/dyouwan/utility/pipeline"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// compressBody 以指定的编码压缩 data
+func compressBody(t *testing.T, encoding string, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	w.Write([]byte(data))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	body := `{"name":"` + strings.Repeat("a", 100) + `"}`
+	p := pipeline.New(DecompressWithOptions(DecompressOptions{MaxSize: 200}))
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Content-Encoding = %q; want removed", r.Header.Get("Content-Encoding"))
+		}
+		rw.Write(data)
+	})
+
+	tests := []struct {
+		header   string
+		encoding string
+		data     string
+		code     int
+	}{
+		{"gzip", "gzip", body, http.StatusOK},
+		{"deflate", "zlib", body, http.StatusOK},
+		{"deflate", "deflate", body, http.StatusOK},
+		{"gzip", "gzip", strings.Repeat("a", 201), http.StatusRequestEntityTooLarge},
+		{"br", "gzip", body, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(compressBody(t, tt.encoding, tt.data)))
+		req.Header.Set("Content-Encoding", tt.header)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+
+		if rec.Code != tt.code {
+			t.Fatalf("%s/%s: code = %d; want %d", tt.header, tt.encoding, rec.Code, tt.code)
+		}
+		if tt.code == http.StatusOK && rec.Body.String() != tt.data {
+			t.Fatalf("%s/%s: body = %q; want %q", tt.header, tt.encoding, rec.Body.String(), tt.data)
+		}
+		if tt.code != http.StatusOK && !strings.Contains(rec.Body.String(), `"error"`) {
+			t.Fatalf("%s/%s: body = %q; want JSON error", tt.header, tt.encoding, rec.Body.String())
+		}
+	}
+}
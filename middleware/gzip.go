@@ -2,42 +2,84 @@ package middleware
 
 import (
 	"compress/gzip"
+	"fmt"
 	"github.com/dyouwan/utility/pipeline"
 	"io"
 	"net/http"
-	"strings"
 )
 
+// GzipOptions Gzip 中间件的选项
+type GzipOptions struct {
+	Level        int      // 压缩级别，取值范围与 compress/gzip 相同
+	MinSize      int      // 响应体达到该大小（字节）才压缩，默认 1024
+	ContentTypes []string // 需要压缩的 Content-Type 前缀，默认为 DefaultCompressibleTypes
+}
+
+// DefaultGzipOptions 默认的 Gzip 选项
+var DefaultGzipOptions = GzipOptions{
+	Level:   gzip.DefaultCompression,
+	MinSize: defaultMinSize,
+}
+
+// gzipPools 各压缩级别的 gzip.Writer 池，下标为级别减去 gzip.HuffmanOnly
+var gzipPools [gzip.BestCompression - gzip.HuffmanOnly + 1]*encoderPool
+
+func init() {
+	for i := range gzipPools {
+		gzipPools[i] = &encoderPool{factory: GzipEncoder(i + gzip.HuffmanOnly)}
+	}
+}
+
+// GzipEncoder 返回以 level 压缩的 gzip 编码器的 EncoderFactory，level 无效时 panic
+func GzipEncoder(level int) EncoderFactory {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		panic(fmt.Sprintf("gzip: invalid compression level: %d", level))
+	}
+	return func(w io.Writer) Encoder {
+		gz, _ := gzip.NewWriterLevel(w, level)
+		return gz
+	}
+}
+
+// Gzip 以指定的压缩级别压缩响应，其余选项使用默认值
 func Gzip(level int) pipeline.HandlerFunc {
+	opts := DefaultGzipOptions
+	opts.Level = level
+	return GzipWithOptions(opts)
+}
+
+// GzipWithOptions 根据选项创建 gzip 压缩中间件。
+// 只有客户端接受 gzip、响应体达到最小大小、Content-Type 在允许列表中并且处理程序没有自行编码时才压缩。
+// 压缩级别无效时 panic
+func GzipWithOptions(opts GzipOptions) pipeline.HandlerFunc {
+	if opts.Level < gzip.HuffmanOnly || opts.Level > gzip.BestCompression {
+		panic(fmt.Sprintf("gzip: invalid compression level: %d", opts.Level))
+	}
+	policy := newCompressPolicy(opts.MinSize, opts.ContentTypes)
+	pool := gzipPools[opts.Level-gzip.HuffmanOnly]
+
 	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-		// 检查客户端是否支持 gzip 压缩
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		if !eligible(r) {
 			next(rw, r)
 			return
 		}
 
-		// 设置响应头，表明响应内容已经被 gzip 压缩
-		rw.Header().Set("Content-Encoding", "gzip")
+		// 响应内容随 Accept-Encoding 变化，缓存需要区分
+		addVary(rw.Header(), "Accept-Encoding")
 
-		// 创建 gzip 编码器，并将响应写入其中
-		gz, err := gzip.NewWriterLevel(rw, level)
-		if err != nil {
-			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		// 检查客户端是否支持 gzip 压缩
+		if acceptEncoding(r.Header.Get("Accept-Encoding")).q("gzip") <= 0 {
+			next(rw, r)
 			return
 		}
-		defer gz.Close()
 
-		gzr := &gzipResponseWriter{Writer: gz, ResponseWriter: rw}
-		next(gzr, r)
+		cw := &compressWriter{
+			ResponseWriter: rw,
+			policy:         policy,
+			encoding:       "gzip",
+			encoders:       pool,
+		}
+		defer cw.Close()
+		next(cw, r)
 	}
 }
-
-// 封装响应写入器
-type gzipResponseWriter struct {
-	io.Writer
-	http.ResponseWriter
-}
-
-func (grw *gzipResponseWriter) Write(b []byte) (int, error) {
-	return grw.Writer.Write(b)
-}
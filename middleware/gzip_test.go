@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"github.com/dyouwan/utility/pipeline"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveGzip 以 Gzip 中间件处理请求
+func serveGzip(handler http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+	p := pipeline.New(Gzip(gzip.DefaultCompression))
+	p.ThenFunc(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	return rec
+}
+
+// gunzip 解压响应体
+func gunzip(t *testing.T, b []byte) string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() = %v", err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("read gzip body: %v", err)
+	}
+	return string(data)
+}
+
+func TestGzip(t *testing.T) {
+	body := `{"data":"` + strings.Repeat("a", 2048) + `"}`
+	rec := serveGzip(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Content-Length", "2060")
+		rw.Write([]byte(body[:100]))
+		rw.Write([]byte(body[100:]))
+	}, "deflate, gzip;q=0.8")
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q; want gzip", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Fatalf("Content-Length = %q; want removed", rec.Header().Get("Content-Length"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Vary = %q; want Accept-Encoding", rec.Header().Get("Vary"))
+	}
+	if got := gunzip(t, rec.Body.Bytes()); got != body {
+		t.Fatalf("body = %q; want %q", got, body)
+	}
+}
+
+func TestGzipSkipped(t *testing.T) {
+	large := strings.Repeat("a", 2048)
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		handler        http.HandlerFunc
+		code           int
+		body           string
+	}{
+		{"not accepted", "", func(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte(large)) }, 200, large},
+		{"q=0", "gzip;q=0", func(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte(large)) }, 200, large},
+		{"small body", "gzip", func(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte("small")) }, 200, "small"},
+		{"no content", "gzip", func(rw http.ResponseWriter, r *http.Request) { rw.WriteHeader(http.StatusNoContent) }, 204, ""},
+		{"compressed type", "gzip", func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "image/png")
+			rw.Write([]byte(large))
+		}, 200, large},
+		{"already encoded", "gzip", func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Encoding", "br")
+			rw.Write([]byte(large))
+		}, 200, large},
+	}
+
+	for _, tt := range tests {
+		rec := serveGzip(tt.handler, tt.acceptEncoding)
+		if rec.Code != tt.code || rec.Body.String() != tt.body {
+			t.Fatalf("%s: response = %d %q; want %d %q", tt.name, rec.Code, rec.Body.String(), tt.code, tt.body)
+		}
+		if rec.Header().Get("Content-Encoding") == "gzip" {
+			t.Fatalf("%s: response compressed", tt.name)
+		}
+		if rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("%s: Vary = %q; want Accept-Encoding", tt.name, rec.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestGzipFlush(t *testing.T) {
+	rec := serveGzip(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Write([]byte("data: 1\n\n"))
+		rw.(http.Flusher).Flush()
+		rw.Write([]byte("data: 2\n\n"))
+	}, "gzip")
+
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Flushed = %v, Content-Encoding = %q; want flushed gzip stream", rec.Flushed, rec.Header().Get("Content-Encoding"))
+	}
+	if got := gunzip(t, rec.Body.Bytes()); got != "data: 1\n\ndata: 2\n\n" {
+		t.Fatalf("body = %q", got)
+	}
+}
+
+// hijackRecorder 支持 Hijack 的 ResponseRecorder
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	return nil, nil, nil
+}
+
+func TestGzipHijack(t *testing.T) {
+	p := pipeline.New(Gzip(gzip.DefaultCompression))
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if _, _, err := http.NewResponseController(rw).Hijack(); err != nil {
+			t.Fatalf("Hijack() = %v", err)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	p.ServeHTTP(rec, req)
+	if !rec.hijacked {
+		t.Fatal("Hijack() not passed through")
+	}
+}
+
+func TestGzipShortCircuit(t *testing.T) {
+	deny := pipeline.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		http.Error(rw, "unauthorized", http.StatusUnauthorized)
+		next(rw, r)
+	})
+	reached := false
+	p := pipeline.New(Gzip(gzip.DefaultCompression), deny)
+	p.ShortCircuit(true)
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) {
+		reached = true
+		rw.Write([]byte("secret"))
+	})
+
+	// 较短的响应体缓存在 Gzip 中，后续的中间件仍然能知道响应已经写入
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if reached || rec.Code != http.StatusUnauthorized || rec.Body.String() != "unauthorized\n" {
+		t.Fatalf("reached = %v, code = %d, body = %q; want 401 without calling the handler", reached, rec.Code, rec.Body.String())
+	}
+}
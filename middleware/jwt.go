@@ -0,0 +1,346 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dyouwan/utility/pipeline"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidToken JWT 格式或签名无效
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired JWT 已经过期
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenNotValidYet JWT 还没有生效
+	ErrTokenNotValidYet = errors.New("token not valid yet")
+	// ErrMissingExpiration JWT 没有 exp
+	ErrMissingExpiration = errors.New("token has no expiration")
+	// ErrInvalidIssuer JWT 的签发者不匹配
+	ErrInvalidIssuer = errors.New("invalid token issuer")
+	// ErrInvalidAudience JWT 的受众不匹配
+	ErrInvalidAudience = errors.New("invalid token audience")
+)
+
+// KeySet 验证 JWT 签名的密钥集合，支持 HS256 的共享密钥和 RS256 的公钥。
+// 通过 kid 区分不同的密钥，可以在运行时重新加载以轮换密钥
+type KeySet struct {
+	mu   sync.RWMutex
+	keys map[string]interface{} // kid 对应的密钥，[]byte 或 *rsa.PublicKey
+}
+
+// NewKeySet 创建一个空的密钥集合
+func NewKeySet() *KeySet {
+	return &KeySet{keys: make(map[string]interface{})}
+}
+
+// AddHMAC 添加一个 HS256 的共享密钥
+func (ks *KeySet) AddHMAC(kid string, secret []byte) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[kid] = secret
+}
+
+// AddRSA 添加一个 RS256 的公钥
+func (ks *KeySet) AddRSA(kid string, key *rsa.PublicKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[kid] = key
+}
+
+// keyFile 密钥文件的格式
+type keyFile struct {
+	Keys []struct {
+		Kid    string `json:"kid"`
+		Alg    string `json:"alg"`    // HS256 或 RS256
+		Secret string `json:"secret"` // HS256 的共享密钥
+		PEM    string `json:"pem"`    // RS256 的 PEM 格式公钥或证书
+	} `json:"keys"`
+}
+
+// LoadKeySet 从 JSON 文件加载密钥集合，参见 KeySet.Load
+func LoadKeySet(path string) (*KeySet, error) {
+	ks := NewKeySet()
+	if err := ks.Load(path); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Load 从 JSON 文件加载密钥并整体替换当前的密钥，文件格式为：
+// {"keys":[{"kid":"k1","alg":"HS256","secret":"..."},{"kid":"k2","alg":"RS256","pem":"-----BEGIN PUBLIC KEY-----..."}]}
+func (ks *KeySet) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parse key file %s: %w", path, err)
+	}
+
+	keys := make(map[string]interface{}, len(file.Keys))
+	for _, key := range file.Keys {
+		switch key.Alg {
+		case "HS256":
+			if key.Secret == "" {
+				return fmt.Errorf("key %q: empty secret", key.Kid)
+			}
+			keys[key.Kid] = []byte(key.Secret)
+		case "RS256":
+			pub, err := parseRSAPublicKey([]byte(key.PEM))
+			if err != nil {
+				return fmt.Errorf("key %q: %w", key.Kid, err)
+			}
+			keys[key.Kid] = pub
+		default:
+			return fmt.Errorf("key %q: unsupported algorithm %q", key.Kid, key.Alg)
+		}
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = keys
+	return nil
+}
+
+// candidates 返回可以验证签名的密钥。指定了 kid 时只使用该密钥，否则尝试所有与算法匹配的密钥
+func (ks *KeySet) candidates(kid string, alg string) []interface{} {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	var keys []interface{}
+	for id, key := range ks.keys {
+		if kid != "" && id != kid {
+			continue
+		}
+		// 算法与密钥类型必须一致，防止用公钥作为 HMAC 密钥伪造签名
+		switch key.(type) {
+		case []byte:
+			if alg == "HS256" {
+				keys = append(keys, key)
+			}
+		case *rsa.PublicKey:
+			if alg == "RS256" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// parseRSAPublicKey 解析 PEM 格式的 RSA 公钥，支持 PKIX、PKCS1 公钥和证书
+func parseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA public key")
+	}
+	return pub, nil
+}
+
+// JWTOptions JWT 中间件的选项
+type JWTOptions struct {
+	Keys            *KeySet          // 验证签名的密钥
+	Issuer          string           // 要求的签发者（iss），为空时不检查
+	Audience        string           // 要求的受众（aud），为空时不检查
+	ClockSkew       time.Duration    // 检查 exp、nbf 时允许的时钟误差
+	AllowMissingExp bool             // 为 true 时接受没有 exp 的 JWT，默认拒绝，避免 JWT 永久有效
+	Now             func() time.Time // 当前时间，默认为 time.Now
+	Authorize       Authorizer       // 认证通过后检查权限，返回 false 时响应 403，可选
+}
+
+// JWT 根据选项创建 JWT Bearer 认证中间件，支持 HS256 和 RS256 签名。
+// 没有 exp 的 JWT 默认被拒绝，参见 JWTOptions.AllowMissingExp。
+// 认证通过后 Principal 的 Subject 为 sub，Claims 为 JWT 的所有 claims；认证失败时响应 401
+func JWT(opts JWTOptions) pipeline.HandlerFunc {
+	if opts.Keys == nil {
+		panic("jwt: key set cannot be nil")
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		token, ok := bearerToken(r)
+		if !ok {
+			unauthorized(rw, "Bearer", "missing bearer token")
+			return
+		}
+
+		claims, err := verifyJWT(token, opts)
+		if err != nil {
+			unauthorized(rw, `Bearer error="invalid_token"`, err.Error())
+			return
+		}
+
+		sub, _ := claims["sub"].(string)
+		authenticate(rw, r, next, &Principal{Subject: sub, Method: "jwt", Claims: claims}, opts.Authorize)
+	}
+}
+
+// bearerToken 返回 Authorization 请求头中的 Bearer token
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// verifyJWT 验证 JWT 的签名和 claims，返回 claims
+func verifyJWT(token string, opts JWTOptions) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !verifySignature(parts[0]+"."+parts[1], signature, opts.Keys.candidates(header.Kid, header.Alg)) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := validateClaims(claims, opts); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifySignature 使用 keys 中的任意一个密钥验证签名
+func verifySignature(signingInput string, signature []byte, keys []interface{}) bool {
+	digest := sha256.Sum256([]byte(signingInput))
+	for _, key := range keys {
+		switch key := key.(type) {
+		case []byte:
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte(signingInput))
+			if hmac.Equal(signature, mac.Sum(nil)) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateClaims 检查 exp、nbf、iss 和 aud
+func validateClaims(claims map[string]interface{}, opts JWTOptions) error {
+	// 以秒为单位比较，超出 time.Time 范围的时间戳不会溢出
+	now := opts.Now()
+	seconds := float64(now.Unix()) + float64(now.Nanosecond())/float64(time.Second)
+	skew := opts.ClockSkew.Seconds()
+
+	exp, ok, err := numericDate(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if !ok && !opts.AllowMissingExp {
+		return ErrMissingExpiration
+	}
+	if ok && seconds > exp+skew {
+		return ErrTokenExpired
+	}
+	nbf, ok, err := numericDate(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if ok && seconds+skew < nbf {
+		return ErrTokenNotValidYet
+	}
+	if opts.Issuer != "" && claims["iss"] != opts.Issuer {
+		return ErrInvalidIssuer
+	}
+	if opts.Audience != "" && !hasAudience(claims["aud"], opts.Audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// hasAudience 判断 aud 中是否包含 audience，aud 可以是字符串或字符串数组
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeSegment 解码 JWT 中 base64url 编码的 JSON
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// numericDate 返回 claims 中名为 name 的时间戳（秒），不存在时 ok 为 false，不是数字时返回 ErrInvalidToken
+func numericDate(claims map[string]interface{}, name string) (seconds float64, ok bool, err error) {
+	value, ok := claims[name]
+	if !ok {
+		return 0, false, nil
+	}
+	seconds, ok = value.(float64)
+	if !ok {
+		return 0, false, ErrInvalidToken
+	}
+	return seconds, true, nil
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/dyouwan/utility/logger"
+	"github.com/dyouwan/utility/pipeline"
+	"github.com/dyouwan/utility/response"
+	"net/http"
+	"runtime/debug"
+)
+
+// RecoverOptions Recover 中间件的选项
+type RecoverOptions struct {
+	// Renderer 发生 panic 后输出响应，为空时输出 response.Error 格式的 500 JSON 响应
+	Renderer func(rw http.ResponseWriter, r *http.Request, err interface{})
+	// Log 记录 panic 的值和堆栈，为空时使用 logger.Error
+	Log func(msg string, source string)
+	// RepanicAbort 为 true 时，http.ErrAbortHandler 引起的 panic 不处理，继续交给 net/http 中断连接
+	RepanicAbort bool
+	// IncludeRequestID 为 true 时，在日志和默认的错误响应中包含 Time 中间件设置的请求 ID
+	IncludeRequestID bool
+}
+
+// DefaultRecoverOptions 默认的 Recover 选项
+var DefaultRecoverOptions = RecoverOptions{
+	RepanicAbort:     true,
+	IncludeRequestID: true,
+}
+
+// Recover 捕获后续处理程序中的 panic，记录日志并输出 500 JSON 响应，避免连接被直接断开
+func Recover() pipeline.HandlerFunc {
+	return RecoverWithOptions(DefaultRecoverOptions)
+}
+
+// RecoverWithOptions 根据选项创建 Recover 中间件
+func RecoverWithOptions(opts RecoverOptions) pipeline.HandlerFunc {
+	if opts.Log == nil {
+		opts.Log = logger.Error
+	}
+
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		// 在 Pipeline 之外使用（例如 ToMiddleware 之后用于 mux）时也需要知道响应是否已经写入
+		w := pipeline.NewResponseWriter(rw)
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler && opts.RepanicAbort {
+				panic(err)
+			}
+
+			requestID := ""
+			if opts.IncludeRequestID {
+				requestID = RequestID(r)
+				// 请求 ID 可能由后续的中间件设置
+				if requestID == "" {
+					requestID = w.Header().Get(RequestIDHeader)
+				}
+			}
+			msg := fmt.Sprintf("panic: %v %s %s\n%s", err, r.Method, r.URL.String(), debug.Stack())
+			if requestID != "" {
+				msg = requestID + " " + msg
+			}
+			opts.Log(msg, "middleware.Recover")
+
+			// 响应已经写入时无法再输出错误响应
+			if w.Written() {
+				return
+			}
+
+			if opts.Renderer != nil {
+				opts.Renderer(w, r, err)
+				return
+			}
+
+			message := http.StatusText(http.StatusInternalServerError)
+			if requestID != "" {
+				response.ErrorWithRequestID(w, message, http.StatusInternalServerError, requestID)
+				return
+			}
+			response.Error(w, message, http.StatusInternalServerError)
+		}()
+
+		next(w, r)
+	}
+}
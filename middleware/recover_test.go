@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/dyouwan/utility/pipeline"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	var logged string
+	opts := DefaultRecoverOptions
+	opts.Log = func(msg string, source string) { logged = msg }
+
+	p := pipeline.New(Time(), RecoverWithOptions(opts))
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("code = %d; want 500", rec.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+	}
+	if body["request_id"] != "req-1" || body["error"] == "" {
+		t.Fatalf("body = %v; want error with request_id req-1", body)
+	}
+	if !strings.HasPrefix(logged, "req-1 panic: boom") || !strings.Contains(logged, "goroutine") {
+		t.Fatalf("logged = %q; want request ID, panic value and stack", logged)
+	}
+}
+
+func TestRecoverBeforeTime(t *testing.T) {
+	var logged string
+	opts := DefaultRecoverOptions
+	opts.Log = func(msg string, source string) { logged = msg }
+
+	// Recover 在 Time 之前时，请求 ID 由 Time 生成并写入响应头
+	p := pipeline.New(RecoverWithOptions(opts), Time())
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user", nil))
+
+	requestID := rec.Header().Get(RequestIDHeader)
+	if requestID == "" {
+		t.Fatal("Time() did not set the request ID header")
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+	}
+	if body["request_id"] != requestID {
+		t.Fatalf("body = %v; want request_id %s", body, requestID)
+	}
+	if !strings.HasPrefix(logged, requestID+" panic: boom") {
+		t.Fatalf("logged = %q; want request ID %s", logged, requestID)
+	}
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	opts := DefaultRecoverOptions
+	opts.Log = func(msg string, source string) {}
+	p := pipeline.New(RecoverWithOptions(opts))
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) })
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Fatalf("recover() = %v; want %v", err, http.ErrAbortHandler)
+		}
+	}()
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestRecoverRenderer(t *testing.T) {
+	opts := RecoverOptions{
+		Log: func(msg string, source string) {},
+		Renderer: func(rw http.ResponseWriter, r *http.Request, err interface{}) {
+			http.Error(rw, "custom", http.StatusServiceUnavailable)
+		},
+	}
+	p := pipeline.New(RecoverWithOptions(opts))
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) })
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("code = %d; want 503", rec.Code)
+	}
+}
+
+func TestRecoverInRouteGroup(t *testing.T) {
+	opts := DefaultRecoverOptions
+	opts.Log = func(msg string, source string) {}
+
+	// 作为分组的中间件使用时，rw 不是 Pipeline 包装的 ResponseWriter
+	router := mux.NewRouter()
+	pipeline.Group(router, "/api", RecoverWithOptions(opts)).AddRoutes([]pipeline.Route{
+		{Name: "partial", Method: http.MethodGet, Pattern: "/partial", HandlerFunc: func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusCreated)
+			rw.Write([]byte("partial"))
+			panic("boom")
+		}},
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/partial", nil))
+	if rec.Code != http.StatusCreated || rec.Body.String() != "partial" {
+		t.Fatalf("code = %d, body = %q; want the partial response untouched", rec.Code, rec.Body.String())
+	}
+}
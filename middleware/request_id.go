@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// RequestIDHeader 请求 ID 使用的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey 请求 ID 在 context 中的键
+type requestIDKey struct{}
+
+// RequestID 返回 Time 中间件为请求设置的请求 ID，没有设置时返回请求头中的请求 ID
+func RequestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return r.Header.Get(RequestIDHeader)
+}
+
+// withRequestID 返回 context 中带有请求 ID 的请求
+func withRequestID(r *http.Request, id string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
@@ -1,37 +1,30 @@
 package middleware
 
 import (
-	"fmt"
 	"github.com/dyouwan/utility/pipeline"
 	"github.com/google/uuid"
 	"net/http"
 	"strings"
-	"time"
 )
 
+// Time 为路径中包含 /api 的请求设置请求 ID，并以默认选项记录访问日志，参见 AccessLog
 func Time() pipeline.HandlerFunc {
+	accessLog := AccessLog()
+
 	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 		if strings.Contains(r.URL.Path, "/api") {
 			// 获取请求 ID
-			requestID := r.Header.Get("X-Request-ID")
+			requestID := r.Header.Get(RequestIDHeader)
 			if requestID == "" {
 				requestID = uuid.New().String()
 			}
 
-			// 将请求 ID 添加到响应头中
-			rw.Header().Set("X-Request-ID", requestID)
-
-			// 记录请求开始时间
-			start := time.Now()
-
-			// 调用下一个处理器
-			next(rw, r)
-
-			// 计算请求处理时间
-			duration := time.Since(start).Milliseconds()
+			// 将请求 ID 添加到响应头和请求的 context 中
+			rw.Header().Set(RequestIDHeader, requestID)
+			r = withRequestID(r, requestID)
 
-			// 输出请求日志
-			logger.Info(fmt.Sprintf("%s %s request %s%s 耗时:%dms", requestID, r.RemoteAddr, r.Host, r.URL.String(), duration))
+			// 调用下一个处理器并记录访问日志
+			accessLog(rw, r, next)
 		} else {
 			next(rw, r)
 		}
@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"context"
+	"net/http"
+)
+
+// nextKey 请求的 context 中保存 FromMiddleware 适配的中间件之后要执行的处理程序
+type nextKey struct{}
+
+// FromMiddleware 将标准形式的中间件 func(http.Handler) http.Handler 适配为管道处理程序，
+// 以便在 Pipeline.Use 中使用第三方中间件。
+// mw 只在适配时调用一次，每个请求的 next 通过请求的 context 传给 mw 包装的处理程序
+func FromMiddleware(mw func(http.Handler) http.Handler) HandlerFunc {
+	if mw == nil {
+		panic("middleware cannot be nil")
+	}
+
+	h := mw(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if next, ok := r.Context().Value(nextKey{}).(http.HandlerFunc); ok {
+			next(rw, r)
+		}
+	}))
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		h.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), nextKey{}, next)))
+	}
+}
+
+// ToMiddleware 将管道处理程序转换为标准形式的中间件 func(http.Handler) http.Handler，
+// 以便在 Pipeline 之外使用，例如 mux.Router.Use
+func ToMiddleware(handler Handler) func(http.Handler) http.Handler {
+	if handler == nil {
+		panic("handler cannot be nil")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			handler.ServeHTTP(rw, r, next.ServeHTTP)
+		})
+	}
+}
+
+// Wrap 将 Pipeline 作为标准形式的中间件使用：依次执行 Pipeline 中的中间件后执行 next。
+// Pipeline 的终端处理程序会被 next 代替
+func (p *Pipeline) Wrap(next http.Handler) http.Handler {
+	p.mu.Lock()
+	m := build(p.handlers, next, p.shortCircuit)
+	p.mu.Unlock()
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		m.ServeHTTP(NewResponseWriter(rw), r)
+	})
+}
@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// traceMiddleware 返回一个标准形式的中间件，在响应头中记录经过顺序
+func traceMiddleware(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Add("X-Trace", name)
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func TestAdapters(t *testing.T) {
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) { rw.Header().Add("X-Trace", "handler") })
+
+	p := New(trace("a"), FromMiddleware(traceMiddleware("std")))
+	p.Use(trace("b"))
+	p.Then(ok)
+
+	// Pipeline 作为标准中间件包装另一个 Pipeline 的中间件
+	outer := ToMiddleware(trace("outer"))
+	handler := outer(New(trace("inner")).Wrap(p))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if trace := strings.Join(rec.Header().Values("X-Trace"), ","); trace != "outer,inner,a,std,b,handler" {
+		t.Fatalf("trace = %q; want %q", trace, "outer,inner,a,std,b,handler")
+	}
+}
+
+func TestFromMiddlewareBuildsOnce(t *testing.T) {
+	var built int
+	mw := func(next http.Handler) http.Handler {
+		built++
+		return traceMiddleware("std")(next)
+	}
+
+	// 同一个适配后的中间件用在两个 Pipeline 中，每个请求执行各自的 next
+	std := FromMiddleware(mw)
+	first := New(std, trace("first"))
+	first.Then(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	second := New(std, trace("second"))
+	second.Then(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		for name, p := range map[string]*Pipeline{"first": first, "second": second} {
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if trace := strings.Join(rec.Header().Values("X-Trace"), ","); trace != "std,"+name {
+				t.Fatalf("trace = %q; want %q", trace, "std,"+name)
+			}
+		}
+	}
+	if built != 1 {
+		t.Fatalf("middleware built %d times; want 1", built)
+	}
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"github.com/gorilla/mux"
+	"strings"
+)
+
+// RouteGroup 路由分组，组内的路由共享路由前缀和中间件。
+// 分组可以嵌套，子分组的前缀和中间件叠加在父分组之上，请求依次经过父分组、子分组和路由自己的中间件
+type RouteGroup struct {
+	router    *mux.Router
+	prefix    string
+	preflight bool // 是否已经注册了处理预检请求的 OPTIONS 路由
+}
+
+// Group 在 router 上创建一个路由前缀为 prefix 的分组，middlewares 作用于组内的所有路由
+func Group(router *mux.Router, prefix string, middlewares ...Handler) *RouteGroup {
+	prefix = strings.TrimSuffix(prefix, "/")
+	g := &RouteGroup{router: subrouter(router, prefix), prefix: prefix}
+	g.Use(middlewares...)
+	return g
+}
+
+// Group 创建一个子分组，子分组的路由前缀为当前分组的前缀加上 prefix
+func (g *RouteGroup) Group(prefix string, middlewares ...Handler) *RouteGroup {
+	child := Group(g.router, prefix, middlewares...)
+	child.prefix = g.prefix + child.prefix
+	return child
+}
+
+// Use 添加作用于组内所有路由的中间件，包括之前已经添加的路由和子分组中的路由。
+// 中间件中有 Preflight 标记的中间件时，为分组注册匹配所有路径的 OPTIONS 路由处理预检请求，
+// 之后添加到该分组的 OPTIONS 路由不会再被匹配到
+func (g *RouteGroup) Use(middlewares ...Handler) {
+	for _, middleware := range middlewares {
+		if middleware == nil {
+			panic("handler cannot be nil")
+		}
+		g.router.Use(ToMiddleware(middleware))
+	}
+
+	// 分组的中间件由 mux 在匹配到路由后执行，OPTIONS 路由本身不需要再执行中间件
+	if !g.preflight && handlesPreflight(middlewares) {
+		g.preflight = true
+		addPreflightRoute(g.router, "", nil)
+	}
+}
+
+// AddRoutes 添加路由，路由的 Pattern 是相对于分组前缀的路径
+func (g *RouteGroup) AddRoutes(routes []Route) {
+	AddRoutes(routes, g.router)
+}
+
+// Prefix 返回分组完整的路由前缀
+func (g *RouteGroup) Prefix() string {
+	return g.prefix
+}
+
+// Router 返回分组对应的 mux.Router
+func (g *RouteGroup) Router() *mux.Router {
+	return g.router
+}
+
+// subrouter 创建路由前缀为 prefix 的子路由，prefix 为空时创建不限制路径的子路由，使分组的中间件不影响 router 上的其他路由
+func subrouter(router *mux.Router, prefix string) *mux.Router {
+	if prefix == "" {
+		return router.NewRoute().Subrouter()
+	}
+	return router.PathPrefix(prefix).Subrouter()
+}
@@ -0,0 +1,50 @@
+package pipeline
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// trace 返回一个在响应头中记录经过顺序的中间件
+func trace(name string) HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		rw.Header().Add("X-Trace", name)
+		next(rw, r)
+	}
+}
+
+func TestRouteGroup(t *testing.T) {
+	router := mux.NewRouter()
+	ok := func(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte(r.URL.Path)) }
+
+	api := Group(router, "/api/", trace("api"))
+	v1 := api.Group("/v1", trace("v1"))
+	v1.AddRoutes([]Route{
+		{Name: "user", Method: http.MethodGet, Pattern: "/user", HandlerFunc: ok, Middlewares: []Handler{trace("route")}},
+	})
+	api.Use(trace("late"))
+	AddRoutes([]Route{{Name: "health", Method: http.MethodGet, Pattern: "/health", HandlerFunc: ok}}, router)
+
+	if v1.Prefix() != "/api/v1" {
+		t.Fatalf("Prefix() = %q; want %q", v1.Prefix(), "/api/v1")
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/user", nil))
+	if rec.Body.String() != "/api/v1/user" {
+		t.Fatalf("body = %q; want %q", rec.Body.String(), "/api/v1/user")
+	}
+	if trace := strings.Join(rec.Header().Values("X-Trace"), ","); trace != "api,late,v1,route" {
+		t.Fatalf("trace = %q; want %q", trace, "api,late,v1,route")
+	}
+
+	// 分组的中间件不影响分组之外的路由
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK || len(rec.Header().Values("X-Trace")) != 0 {
+		t.Fatalf("code = %d, trace = %v; want 200 without group middleware", rec.Code, rec.Header().Values("X-Trace"))
+	}
+}
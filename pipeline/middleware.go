@@ -8,17 +8,31 @@ import (
 type middleware struct {
 	handler Handler
 	next    *middleware
+	skip    bool // 响应已经写入时跳过该中间件
 }
 
 // ServeHTTP 实现底层http.Handler接口
 func (m middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if m.skip && written(rw) {
+		return
+	}
 	m.handler.ServeHTTP(rw, r, m.next.ServeHTTP)
 }
 
 // VoidMiddleware 空的中间件，作为末尾使用
 func VoidMiddleware() middleware {
 	return middleware{
-		HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {}),
-		&middleware{},
+		handler: HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {}),
+		next:    &middleware{},
+	}
+}
+
+// terminalMiddleware 执行终端处理程序的中间件，作为末尾使用
+func terminalMiddleware(h http.Handler) middleware {
+	return middleware{
+		handler: HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			h.ServeHTTP(rw, r)
+		}),
+		next: &middleware{},
 	}
 }
@@ -0,0 +1,32 @@
+package pipeline
+
+import (
+	"net/http"
+)
+
+// namedHandler 命名的中间件
+type namedHandler struct {
+	Handler
+	name string
+}
+
+// Named 为中间件命名，Pipeline.InsertBefore 和 Pipeline.InsertAfter 通过名称定位中间件
+func Named(name string, handler Handler) Handler {
+	if handler == nil {
+		panic("handler cannot be nil")
+	}
+	return namedHandler{Handler: handler, name: name}
+}
+
+// ServeHTTP 实现Handler接口
+func (h namedHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	h.Handler.ServeHTTP(rw, r, next)
+}
+
+// nameOf 返回中间件的名称，没有命名时返回空字符串
+func nameOf(handler Handler) string {
+	if h, ok := handler.(namedHandler); ok {
+		return h.name
+	}
+	return ""
+}
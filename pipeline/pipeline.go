@@ -1,40 +1,54 @@
 package pipeline
 
 import (
+	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 )
 
-// Pipeline 负责处理http请求管道模型
+// Pipeline 负责处理http请求管道模型。
+// 编译好的中间件链不会被修改，Use 等方法会编译新的中间件链后整体替换，因此可以在处理请求的同时添加中间件；
+// With、Append、Prepend、InsertBefore、InsertAfter、WithTerminal、WithShortCircuit 不修改原来的 Pipeline，而是返回一个新的 Pipeline；
+// Use、Then、ShortCircuit 则直接修改 Pipeline 本身，共享的 Pipeline 应该使用返回新 Pipeline 的方法
 type Pipeline struct {
-	middleware middleware
-	handlers   []Handler
+	mu           sync.Mutex
+	handlers     []Handler
+	handler      http.Handler // 终端处理程序，所有中间件之后执行，不再调用 next
+	shortCircuit bool         // 响应已经写入后跳过剩余的中间件和终端处理程序
+
+	middleware atomic.Pointer[middleware] // 编译好的中间件链
 }
 
 // New 创建一个管道模型
 func New(handlers ...Handler) *Pipeline {
-	return &Pipeline{
-		handlers:   handlers,
-		middleware: build(handlers),
-	}
+	p := &Pipeline{handlers: append([]Handler(nil), handlers...)}
+	p.compile()
+	return p
 }
 
 // NewPipeline allocates and returns a new Pipeline.
 func NewPipeline() *Pipeline { return new(Pipeline) }
 
-// ServeHTTP 实现底层http.Handler接口
+// ServeHTTP 实现底层http.Handler接口，rw 会被包装成 ResponseWriter 以记录响应状态
 func (p *Pipeline) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	p.middleware.ServeHTTP(rw, r)
+	m := p.middleware.Load()
+	if m == nil {
+		return
+	}
+	m.ServeHTTP(NewResponseWriter(rw), r)
 }
 
 // Use 添加中间件时，会将所有中间件进行编译，形成中间件链。
 // 执行Pipeline的ServeHTTP方法时，实际是执行第一个中间件，然后根据中间件的链，依次调用下一个中间件
 func (p *Pipeline) Use(handler Handler) {
-	if handler == nil {
-		panic("handler cannot be nil")
-	}
+	mustHandlers(handler)
 
-	p.handlers = append(p.handlers, handler)
-	p.middleware = build(p.handlers)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// 总是复制一份，不修改已经被其他 Pipeline 共享的底层数组
+	p.handlers = append(p.handlers[:len(p.handlers):len(p.handlers)], handler)
+	p.compile()
 }
 
 // UseHandler 添加处理程序
@@ -42,6 +56,139 @@ func (p *Pipeline) UseHandler(handler http.Handler) {
 	p.Use(Adapt(handler))
 }
 
+// Then 设置终端处理程序，所有中间件调用 next 之后执行，返回 Pipeline 本身以便直接注册为 http.Handler。
+// 与 UseHandler 不同，终端处理程序之后不再有中间件。Then 修改 Pipeline 本身，不修改原来的 Pipeline 时使用 WithTerminal
+func (p *Pipeline) Then(handler http.Handler) *Pipeline {
+	if handler == nil {
+		panic("handler cannot be nil")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handler = handler
+	p.compile()
+	return p
+}
+
+// ThenFunc 以函数设置终端处理程序，参见 Then
+func (p *Pipeline) ThenFunc(fn http.HandlerFunc) *Pipeline {
+	return p.Then(fn)
+}
+
+// ShortCircuit 设置响应已经写入（写入了响应头）后是否跳过剩余的中间件和终端处理程序。
+// 例如认证失败的中间件写入 401 后调用了 next，开启后后续的处理程序不会执行。
+// ShortCircuit 修改 Pipeline 本身，不修改原来的 Pipeline 时使用 WithShortCircuit
+func (p *Pipeline) ShortCircuit(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.shortCircuit = enabled
+	p.compile()
+}
+
+// WithTerminal 返回一个以 handler 为终端处理程序的新 Pipeline，原来的 Pipeline 不变，参见 Then
+func (p *Pipeline) WithTerminal(handler http.Handler) *Pipeline {
+	if handler == nil {
+		panic("handler cannot be nil")
+	}
+
+	derived := p.Clone()
+	derived.handler = handler
+	derived.compile()
+	return derived
+}
+
+// WithTerminalFunc 返回一个以函数为终端处理程序的新 Pipeline，参见 WithTerminal
+func (p *Pipeline) WithTerminalFunc(fn http.HandlerFunc) *Pipeline {
+	return p.WithTerminal(fn)
+}
+
+// WithShortCircuit 返回一个设置了是否短路的新 Pipeline，原来的 Pipeline 不变，参见 ShortCircuit
+func (p *Pipeline) WithShortCircuit(enabled bool) *Pipeline {
+	derived := p.Clone()
+	derived.shortCircuit = enabled
+	derived.compile()
+	return derived
+}
+
+// Handlers 返回 Pipeline 中的中间件
+func (p *Pipeline) Handlers() []Handler {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Handler(nil), p.handlers...)
+}
+
+// Clone 返回 Pipeline 的副本，修改副本不影响原来的 Pipeline
+func (p *Pipeline) Clone() *Pipeline {
+	return p.derive(func(handlers []Handler) []Handler { return handlers })
+}
+
+// With 返回一个在末尾添加了 handlers 的新 Pipeline，原来的 Pipeline 不变
+func (p *Pipeline) With(handlers ...Handler) *Pipeline {
+	return p.Append(handlers...)
+}
+
+// Append 返回一个在末尾添加了 handlers 的新 Pipeline，原来的 Pipeline 不变
+func (p *Pipeline) Append(handlers ...Handler) *Pipeline {
+	mustHandlers(handlers...)
+	return p.derive(func(current []Handler) []Handler {
+		return append(current, handlers...)
+	})
+}
+
+// Prepend 返回一个在开头添加了 handlers 的新 Pipeline，原来的 Pipeline 不变
+func (p *Pipeline) Prepend(handlers ...Handler) *Pipeline {
+	mustHandlers(handlers...)
+	return p.derive(func(current []Handler) []Handler {
+		return append(append([]Handler(nil), handlers...), current...)
+	})
+}
+
+// InsertBefore 返回一个在名为 name 的中间件之前插入了 handlers 的新 Pipeline，原来的 Pipeline 不变。
+// 中间件通过 Named 命名，找不到该中间件时 panic
+func (p *Pipeline) InsertBefore(name string, handlers ...Handler) *Pipeline {
+	return p.insert(name, 0, handlers)
+}
+
+// InsertAfter 返回一个在名为 name 的中间件之后插入了 handlers 的新 Pipeline，原来的 Pipeline 不变。
+// 中间件通过 Named 命名，找不到该中间件时 panic
+func (p *Pipeline) InsertAfter(name string, handlers ...Handler) *Pipeline {
+	return p.insert(name, 1, handlers)
+}
+
+// insert 在名为 name 的中间件的位置加上 offset 处插入 handlers
+func (p *Pipeline) insert(name string, offset int, handlers []Handler) *Pipeline {
+	mustHandlers(handlers...)
+	return p.derive(func(current []Handler) []Handler {
+		for i, handler := range current {
+			if name == "" || nameOf(handler) != name {
+				continue
+			}
+			i += offset
+			result := append(current[:i:i], handlers...)
+			return append(result, current[i:]...)
+		}
+		panic(fmt.Sprintf("middleware %q not found", name))
+	})
+}
+
+// derive 以当前的配置创建一个新的 Pipeline，modify 根据当前中间件的副本返回新 Pipeline 的中间件
+func (p *Pipeline) derive(modify func(handlers []Handler) []Handler) *Pipeline {
+	p.mu.Lock()
+	handlers := append([]Handler(nil), p.handlers...)
+	derived := &Pipeline{handler: p.handler, shortCircuit: p.shortCircuit}
+	p.mu.Unlock()
+
+	derived.handlers = modify(handlers)
+	derived.compile()
+	return derived
+}
+
+// compile 编译中间件链并替换正在使用的中间件链，调用时需要持有锁或者 Pipeline 还没有被共享
+func (p *Pipeline) compile() {
+	m := build(p.handlers, p.handler, p.shortCircuit)
+	p.middleware.Store(&m)
+}
+
 // Adapt 适配器
 func Adapt(h http.Handler) HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
@@ -50,28 +197,29 @@ func Adapt(h http.Handler) HandlerFunc {
 	}
 }
 
-// build 中间件链
-func build(handlers []Handler) middleware {
-	voidMiddleware := VoidMiddleware()
-
-	switch len(handlers) {
-	case 0:
-		return VoidMiddleware()
-	case 1:
-		return middleware{handlers[0], &voidMiddleware}
-	case 2:
-		return middleware{handlers[0], &middleware{handlers[1], &voidMiddleware}}
-	case 3:
-		return middleware{handlers[0], &middleware{handlers[1], &middleware{handlers[2], &voidMiddleware}}}
-	default:
-		var stack []middleware
-		for i := len(handlers) - 1; i >= 0; i-- {
-			m := middleware{handlers[i], &voidMiddleware}
-			if len(stack) > 0 {
-				m.next = &stack[len(stack)-1]
-			}
-			stack = append(stack, m)
+// build 中间件链，terminal 不为空时作为末尾执行，否则以 VoidMiddleware 结束。
+// skip 为 true 时，除第一个中间件外，响应已经写入后跳过其余的中间件。
+// 每个节点都是新分配的，编译好的中间件链不引用 handlers 的底层数组
+func build(handlers []Handler, terminal http.Handler, skip bool) middleware {
+	tail := VoidMiddleware()
+	if terminal != nil {
+		tail = terminalMiddleware(terminal)
+	}
+	tail.skip = skip
+
+	m := tail
+	for i := len(handlers) - 1; i >= 0; i-- {
+		next := m
+		m = middleware{handler: handlers[i], next: &next, skip: skip && i > 0}
+	}
+	return m
+}
+
+// mustHandlers 检查中间件不为空
+func mustHandlers(handlers ...Handler) {
+	for _, handler := range handlers {
+		if handler == nil {
+			panic("handler cannot be nil")
 		}
-		return stack[len(stack)-1]
 	}
 }
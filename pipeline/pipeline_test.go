@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestThen(t *testing.T) {
+	var status, size int
+	p := New(trace("a"), HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		next(rw, r)
+		w := rw.(ResponseWriter)
+		status, size = w.Status(), w.Size()
+	}))
+	p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte("created"))
+	})
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusCreated || rec.Body.String() != "created" {
+		t.Fatalf("response = %d %q; want 201 %q", rec.Code, rec.Body.String(), "created")
+	}
+	if status != http.StatusCreated || size != len("created") {
+		t.Fatalf("Status() = %d, Size() = %d; want 201, %d", status, size, len("created"))
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	deny := HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		http.Error(rw, "unauthorized", http.StatusUnauthorized)
+		next(rw, r)
+	})
+
+	for _, enabled := range []bool{false, true} {
+		p := New(deny, trace("after"))
+		p.ShortCircuit(enabled)
+		reached := false
+		p.ThenFunc(func(rw http.ResponseWriter, r *http.Request) { reached = true })
+
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("code = %d; want 401", rec.Code)
+		}
+		// 开启后写入响应之后的中间件和终端处理程序都不会执行
+		if reached == enabled || (rec.Header().Get("X-Trace") != "") == enabled {
+			t.Fatalf("ShortCircuit(%v): terminal reached = %v, trace = %q", enabled, reached, strings.Join(rec.Header().Values("X-Trace"), ","))
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	base := New(Named("a", trace("a")), Named("c", trace("c")))
+	ok := func(rw http.ResponseWriter, r *http.Request) {}
+
+	tests := []struct {
+		pipeline *Pipeline
+		want     string
+	}{
+		{base, "a,c"},
+		{base.With(trace("d")), "a,c,d"},
+		{base.Prepend(trace("z")), "z,a,c"},
+		{base.InsertBefore("c", trace("b")), "a,b,c"},
+		{base.InsertAfter("a", trace("b")), "a,b,c"},
+		{base.InsertAfter("c", trace("d"), trace("e")), "a,c,d,e"},
+		{base.Clone(), "a,c"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.pipeline.WithTerminalFunc(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if trace := strings.Join(rec.Header().Values("X-Trace"), ","); trace != tt.want {
+			t.Fatalf("trace = %q; want %q", trace, tt.want)
+		}
+	}
+
+	// 派生的终端处理程序和短路设置不影响原来的 Pipeline
+	deny := New(HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		rw.WriteHeader(http.StatusForbidden)
+		next(rw, r)
+	}), trace("after"))
+	reached := false
+	derived := deny.WithShortCircuit(true).WithTerminalFunc(func(rw http.ResponseWriter, r *http.Request) { reached = true })
+	rec := httptest.NewRecorder()
+	derived.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if reached || rec.Header().Get("X-Trace") != "" {
+		t.Fatalf("derived: terminal reached = %v, trace = %q; want short-circuited", reached, rec.Header().Get("X-Trace"))
+	}
+	rec = httptest.NewRecorder()
+	deny.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if reached || rec.Header().Get("X-Trace") != "after" {
+		t.Fatalf("base: terminal reached = %v, trace = %q; want %q only", reached, rec.Header().Get("X-Trace"), "after")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("InsertBefore() with unknown name did not panic")
+		}
+	}()
+	base.InsertBefore("unknown", trace("x"))
+}
+
+func TestConcurrentBuild(t *testing.T) {
+	base := New(Named("a", trace("a")))
+	base.ThenFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				rec := httptest.NewRecorder()
+				base.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+				if rec.Header().Values("X-Trace")[0] != "a" {
+					t.Errorf("trace = %v; want to start with a", rec.Header().Values("X-Trace"))
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				derived := base.With(trace("b")).InsertBefore("a", trace("z"))
+				derived.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+				base.Use(trace("c"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(base.Handlers()); n != 1+8*100 {
+		t.Fatalf("len(Handlers()) = %d; want %d", n, 1+8*100)
+	}
+}
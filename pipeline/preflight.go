@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// preflightHandler 处理 CORS 预检请求的中间件
+type preflightHandler struct {
+	Handler
+}
+
+// Preflight 标记处理 CORS 预检请求（OPTIONS）的中间件。
+// mux 只在方法匹配时执行路由和分组的中间件，路由或分组使用了标记的中间件时会同时注册 OPTIONS 方法，
+// 使预检请求能够到达该中间件，而不是直接得到 mux 的 405 响应
+func Preflight(handler Handler) Handler {
+	if handler == nil {
+		panic("handler cannot be nil")
+	}
+	return preflightHandler{Handler: handler}
+}
+
+// handlesPreflight 判断中间件中是否有处理预检请求的中间件
+func handlesPreflight(handlers []Handler) bool {
+	for _, handler := range handlers {
+		if h, ok := handler.(namedHandler); ok {
+			handler = h.Handler
+		}
+		if _, ok := handler.(preflightHandler); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// addPreflightRoute 在 router 上为路径 pattern 注册 OPTIONS 方法，pattern 为空时匹配 router 下的所有路径。
+// 预检请求由 middlewares 中的中间件响应，其他的 OPTIONS 请求得到空的 200 响应
+func addPreflightRoute(router *mux.Router, pattern string, middlewares []Handler) {
+	route := router.Methods(http.MethodOptions)
+	if pattern != "" {
+		route.Path(pattern)
+	}
+	route.Handler(New(middlewares...))
+}
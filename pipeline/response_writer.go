@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter 记录响应状态的 http.ResponseWriter，Pipeline 会将请求的 ResponseWriter 包装成该类型
+type ResponseWriter interface {
+	http.ResponseWriter
+	// Status 返回响应的状态码，还没有写入响应头时返回 0
+	Status() int
+	// Size 返回已经写入的响应体字节数
+	Size() int
+	// Written 返回响应头是否已经写入，写入后不能再修改状态码和响应头
+	Written() bool
+}
+
+// responseWriter 实现 ResponseWriter 接口
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+// NewResponseWriter 包装 rw 以记录响应状态，rw 已经是 ResponseWriter 时直接返回
+func NewResponseWriter(rw http.ResponseWriter) ResponseWriter {
+	if w, ok := rw.(ResponseWriter); ok {
+		return w
+	}
+	return &responseWriter{ResponseWriter: rw}
+}
+
+// WriteHeader 写入响应头，只有第一次调用有效
+func (w *responseWriter) WriteHeader(status int) {
+	if w.Written() {
+		return
+	}
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write 写入响应体，没有写入响应头时先写入 200 状态码
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Status 实现 ResponseWriter 接口
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+// Size 实现 ResponseWriter 接口
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+// Written 实现 ResponseWriter 接口
+func (w *responseWriter) Written() bool {
+	return w.status != 0
+}
+
+// Flush 实现 http.Flusher 接口，底层的 ResponseWriter 不支持时忽略
+func (w *responseWriter) Flush() {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack 实现 http.Hijacker 接口
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap 返回被包装的 ResponseWriter，供 http.ResponseController 使用
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// written 返回 rw 或者它包装的 ResponseWriter 是否已经写入响应头，无法判断时返回 false
+func written(rw http.ResponseWriter) bool {
+	for {
+		switch w := rw.(type) {
+		case ResponseWriter:
+			return w.Written()
+		case interface{ Unwrap() http.ResponseWriter }:
+			rw = w.Unwrap()
+		default:
+			return false
+		}
+	}
+}
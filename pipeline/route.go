@@ -15,7 +15,8 @@ type Route struct {
 }
 
 // AddRoutes 添加路由
-// AddRoutes 原生http.ServerMux 功能比较单一，所以这里使用github.com/gorilla/mux
+// AddRoutes 原生http.ServerMux 功能比较单一，所以这里使用github.com/gorilla/mux。
+// 路由的中间件中有 Preflight 标记的中间件时，同时为该路径注册 OPTIONS 方法处理预检请求
 func AddRoutes(routes []Route, router *mux.Router) {
 	var (
 		handler  http.Handler
@@ -30,12 +31,15 @@ func AddRoutes(routes []Route, router *mux.Router) {
 				pipeline.Use(middleware)
 			}
 
-			pipeline.Use(Adapt(route.HandlerFunc))
+			pipeline.Then(route.HandlerFunc)
 			handler = pipeline
 		} else {
 			handler = route.HandlerFunc
 		}
 
 		router.Methods(route.Method).Path(route.Pattern).Name(route.Name).Handler(handler)
+		if route.Method != http.MethodOptions && handlesPreflight(route.Middlewares) {
+			addPreflightRoute(router, route.Pattern, route.Middlewares)
+		}
 	}
 }
@@ -0,0 +1,123 @@
+package pool
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// dispatchPool 是改为由 worker 直接取任务之前的设计：
+// 由一个 dispatch goroutine 取出任务，再等待空闲的 worker 注册后把任务交给它，仅用于基准测试对比
+type dispatchPool struct {
+	workers chan chan Job
+	jobs    chan Job
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newDispatchPool(maxWorker, queueSize int) *dispatchPool {
+	p := &dispatchPool{
+		workers: make(chan chan Job, maxWorker),
+		jobs:    make(chan Job, queueSize),
+		quit:    make(chan struct{}),
+	}
+	for i := 0; i < maxWorker; i++ {
+		jobQueue := make(chan Job)
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for {
+				p.workers <- jobQueue
+				select {
+				case job := <-jobQueue:
+					job.Do()
+				case <-p.quit:
+					return
+				}
+			}
+		}()
+	}
+	go p.dispatch()
+	return p
+}
+
+func (p *dispatchPool) dispatch() {
+	for {
+		select {
+		case job := <-p.jobs:
+			select {
+			case jobQueue := <-p.workers:
+				jobQueue <- job
+			case <-p.quit:
+				return
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *dispatchPool) Submit(job Job) {
+	p.jobs <- job
+}
+
+func (p *dispatchPool) Stop() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// spinJob 模拟一个占用少量 CPU 的任务
+type spinJob struct {
+	n  int
+	wg *sync.WaitGroup
+}
+
+func (j spinJob) Do() {
+	x := 0
+	for i := 0; i < j.n; i++ {
+		x += i * i
+	}
+	_ = x
+	j.wg.Done()
+}
+
+var benchWorkers = []int{1, 4, 16}
+
+func BenchmarkWorkerPool(b *testing.B) {
+	for _, workers := range benchWorkers {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			p := NewWorkerPoolWithOptions(Options{MaxWorker: workers, QueueSize: 1024})
+			p.Start()
+			defer p.Stop()
+
+			var wg sync.WaitGroup
+			wg.Add(b.N)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					p.Submit(spinJob{n: 1000, wg: &wg})
+				}
+			})
+			wg.Wait()
+		})
+	}
+}
+
+func BenchmarkDispatchPool(b *testing.B) {
+	for _, workers := range benchWorkers {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			p := newDispatchPool(workers, 1024)
+			defer p.Stop()
+
+			var wg sync.WaitGroup
+			wg.Add(b.N)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					p.Submit(spinJob{n: 1000, wg: &wg})
+				}
+			})
+			wg.Wait()
+		})
+	}
+}
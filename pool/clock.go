@@ -0,0 +1,36 @@
+package pool
+
+import "time"
+
+// Clock 时钟，测试时可以替换为手动推进的时钟
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer Clock 创建的定时器
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// RealClock 使用系统时间的时钟
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
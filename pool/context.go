@@ -0,0 +1,177 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ContextJob 可以感知取消的任务。ctx 在 worker pool 放弃尚未完成的任务（Stop 或 Shutdown 超时）、
+// 提交时的 ctx 结束或者任务超过截止时间时结束，任务应当尽快返回 ctx 的错误
+type ContextJob interface {
+	Do(ctx context.Context) error
+}
+
+// ContextJobFunc 将函数适配为 ContextJob
+type ContextJobFunc func(ctx context.Context) error
+
+// Do 实现 ContextJob 接口
+func (f ContextJobFunc) Do(ctx context.Context) error {
+	return f(ctx)
+}
+
+// deadlineJob 为任务指定截止时间的包装
+type deadlineJob struct {
+	ContextJob
+	deadline time.Time
+	timeout  time.Duration
+}
+
+// WithDeadline 为任务指定截止时间，任务在截止时间之后才开始执行时不再执行
+func WithDeadline(job ContextJob, deadline time.Time) ContextJob {
+	return &deadlineJob{ContextJob: job, deadline: deadline}
+}
+
+// WithTimeout 限制任务的执行时间，从任务开始执行时计时
+func WithTimeout(job ContextJob, timeout time.Duration) ContextJob {
+	return &deadlineJob{ContextJob: job, timeout: timeout}
+}
+
+// Do 实现 ContextJob 接口
+func (j *deadlineJob) Do(ctx context.Context) error {
+	var cancel context.CancelFunc
+	if j.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+	} else {
+		ctx, cancel = context.WithDeadline(ctx, j.deadline)
+	}
+	defer cancel()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return j.ContextJob.Do(ctx)
+}
+
+// Priority 实现 Prioritized 接口，返回被包装任务的优先级
+func (j *deadlineJob) Priority() Priority {
+	return contextPriority(j.ContextJob)
+}
+
+// contextPriority 返回 ContextJob 的优先级，没有指定时为 PriorityNormal
+func contextPriority(job ContextJob) Priority {
+	if pj, ok := job.(Prioritized); ok {
+		return pj.Priority()
+	}
+	return PriorityNormal
+}
+
+// doer 执行后返回错误的任务，worker 会上报并统计返回的错误。包装其他任务的任务需要转发 do
+type doer interface {
+	do() error
+}
+
+// doJob 执行任务，任务实现了 doer 接口时返回任务的错误
+func doJob(job Job) error {
+	if d, ok := job.(doer); ok {
+		return d.do()
+	}
+	job.Do()
+	return nil
+}
+
+// contextJob 将 ContextJob 适配为 Job
+type contextJob struct {
+	ctx  context.Context
+	job  ContextJob
+	pool *WorkerPool
+}
+
+// ContextJobOf 返回通过 SubmitJob 提交的原始任务，用于在 ErrorHandler 等回调中识别任务
+func ContextJobOf(job Job) (ContextJob, bool) {
+	if j, ok := unwrap(job).(*contextJob); ok {
+		return j.job, true
+	}
+	return nil, false
+}
+
+// SubmitJob 提交一个可以感知取消的任务，ctx 结束前任务没有进入队列时返回 ctx 的错误。
+// 任务执行时的 ctx 继承提交时的 ctx，并在 worker pool 放弃尚未完成的任务时结束。
+// 任务因为取消或超时没有完成时，上报和统计的错误分别是 ErrJobCanceled 和 ErrJobExpired，与执行失败区分开
+func (p *WorkerPool) SubmitJob(ctx context.Context, job ContextJob) error {
+	return p.SubmitContext(ctx, p.AsJob(ctx, job))
+}
+
+// AsJob 将可以感知取消的任务适配为 Job，执行时的 ctx 和错误的上报、统计与 SubmitJob 相同。
+// 用于通过 WithPriority、WithGroup、SubmitKeyed 或调度器提交 ContextJob
+func (p *WorkerPool) AsJob(ctx context.Context, job ContextJob) Job {
+	return &contextJob{ctx: ctx, job: job, pool: p}
+}
+
+// Do 实现 Job 接口
+func (j *contextJob) Do() {
+	_ = j.do()
+}
+
+// do 执行任务，返回归类后的错误
+func (j *contextJob) do() error {
+	ctx, cancel := j.pool.jobContext(j.ctx)
+	defer cancel()
+
+	// 任务开始前 ctx 已经结束，则不再执行
+	if err := ctx.Err(); err != nil {
+		return canceledError(err, err)
+	}
+
+	err := j.job.Do(ctx)
+	if err == nil {
+		return nil
+	}
+	if cause := ctx.Err(); cause != nil {
+		return canceledError(cause, err)
+	}
+	// WithDeadline、WithTimeout 设置的截止时间只作用于任务内部的 ctx，只能通过返回的错误判断
+	if errors.Is(err, context.DeadlineExceeded) {
+		return canceledError(err, err)
+	}
+	return err
+}
+
+// Priority 实现 Prioritized 接口
+func (j *contextJob) Priority() Priority {
+	return contextPriority(j.job)
+}
+
+// jobContext 返回任务执行时使用的 ctx，在 parent 结束或 worker pool 放弃尚未完成的任务时结束
+func (p *WorkerPool) jobContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	select {
+	case <-p.abort:
+		cancel(ErrPoolClosed)
+		return ctx, func() {}
+	default:
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-p.abort:
+			cancel(ErrPoolClosed)
+		case <-ctx.Done():
+		case <-stop:
+		}
+	}()
+	return ctx, func() {
+		close(stop)
+		cancel(context.Canceled)
+	}
+}
+
+// canceledError 将因为 ctx 结束而没有完成的任务的错误 err 按照 ctx 结束的原因 cause 归类为 ErrJobExpired 或 ErrJobCanceled
+func canceledError(cause, err error) error {
+	if errors.Is(cause, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrJobExpired, err)
+	}
+	return fmt.Errorf("%w: %w", ErrJobCanceled, err)
+}
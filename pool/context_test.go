@@ -0,0 +1,137 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errorRecorder 记录 ErrorHandler 收到的错误
+type errorRecorder struct {
+	mu   sync.Mutex
+	errs []error
+	jobs []Job
+}
+
+func (r *errorRecorder) handle(job Job, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = append(r.jobs, job)
+	r.errs = append(r.errs, err)
+}
+
+func (r *errorRecorder) last() (Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.errs) == 0 {
+		return nil, nil
+	}
+	return r.jobs[len(r.jobs)-1], r.errs[len(r.errs)-1]
+}
+
+func TestSubmitJobCanceledOnStop(t *testing.T) {
+	var recorder errorRecorder
+	p := NewWorkerPoolWithOptions(Options{MaxWorker: 1, ErrorHandler: recorder.handle})
+	p.Start()
+
+	started := make(chan struct{})
+	cause := make(chan error, 1)
+	p.SubmitJob(context.Background(), ContextJobFunc(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		return ctx.Err()
+	}))
+	<-started
+
+	p.Stop()
+	<-p.done
+
+	if err := <-cause; err != ErrPoolClosed {
+		t.Fatalf("context.Cause() = %v; want %v", err, ErrPoolClosed)
+	}
+	if _, err := recorder.last(); !errors.Is(err, ErrJobCanceled) {
+		t.Fatalf("reported error = %v; want %v", err, ErrJobCanceled)
+	}
+	if stats := p.Stats(); stats.Canceled != 1 || stats.Failed != 0 {
+		t.Fatalf("Canceled = %d, Failed = %d; want 1, 0", stats.Canceled, stats.Failed)
+	}
+}
+
+func TestSubmitJobDeadline(t *testing.T) {
+	var recorder errorRecorder
+	p := NewWorkerPoolWithOptions(Options{MaxWorker: 1, ErrorHandler: recorder.handle})
+	p.Start()
+
+	p.SubmitJob(context.Background(), WithTimeout(ContextJobFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), 10*time.Millisecond))
+
+	// 截止时间已过的任务不会被执行
+	executed := false
+	p.SubmitJob(context.Background(), WithDeadline(ContextJobFunc(func(ctx context.Context) error {
+		executed = true
+		return nil
+	}), time.Now().Add(-time.Second)))
+
+	p.Shutdown(context.Background())
+
+	if executed {
+		t.Fatal("job executed after its deadline")
+	}
+	if _, err := recorder.last(); !errors.Is(err, ErrJobExpired) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("reported error = %v; want %v", err, ErrJobExpired)
+	}
+	if stats := p.Stats(); stats.Expired != 2 || stats.Failed != 0 {
+		t.Fatalf("Expired = %d, Failed = %d; want 2, 0", stats.Expired, stats.Failed)
+	}
+}
+
+func TestSubmitJobFailure(t *testing.T) {
+	var recorder errorRecorder
+	p := NewWorkerPoolWithOptions(Options{MaxWorker: 1, ErrorHandler: recorder.handle})
+	p.Start()
+
+	errFailed := errors.New("failed")
+	job := ContextJobFunc(func(ctx context.Context) error { return errFailed })
+	p.SubmitJob(context.Background(), job)
+	p.Shutdown(context.Background())
+
+	reported, err := recorder.last()
+	if err != errFailed {
+		t.Fatalf("reported error = %v; want %v", err, errFailed)
+	}
+	if _, ok := ContextJobOf(reported); !ok {
+		t.Fatal("ContextJobOf() = false; want the submitted job")
+	}
+	if stats := p.Stats(); stats.Failed != 1 || stats.Canceled != 0 || stats.Expired != 0 {
+		t.Fatalf("Failed = %d, Canceled = %d, Expired = %d; want 1, 0, 0", stats.Failed, stats.Canceled, stats.Expired)
+	}
+}
+
+func TestAsJobWrapped(t *testing.T) {
+	var recorder errorRecorder
+	p := NewWorkerPoolWithOptions(Options{
+		MaxWorker:    1,
+		ErrorHandler: recorder.handle,
+		RateLimits:   map[string]*Limiter{"api": NewLimiter(1000, 10)},
+	})
+	p.Start()
+
+	errFailed := errors.New("failed")
+	job := p.AsJob(context.Background(), ContextJobFunc(func(ctx context.Context) error { return errFailed }))
+	p.Submit(WithPriority(job, PriorityHigh))
+	p.Submit(WithGroup(job, "api"))
+	p.SubmitKeyed("key", job)
+	p.Shutdown(context.Background())
+
+	if stats := p.Stats(); stats.Failed != 3 {
+		t.Fatalf("Failed = %d; want errors of wrapped jobs to be counted", stats.Failed)
+	}
+	if reported, err := recorder.last(); err != errFailed || reported != job {
+		t.Fatalf("reported = %v, %v; want the original job and %v", reported, err, errFailed)
+	}
+}
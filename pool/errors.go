@@ -0,0 +1,43 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrPoolClosed worker pool 已关闭后提交任务返回的错误
+	ErrPoolClosed = errors.New("worker pool is closed")
+	// ErrQueueFull 任务队列已满时 ErrorPolicy 返回的错误
+	ErrQueueFull = errors.New("worker pool queue is full")
+	// ErrJobDropped 任务被拒绝策略丢弃时上报的错误
+	ErrJobDropped = errors.New("job dropped")
+	// ErrSubmitTimeout SubmitTimeout 超时返回的错误
+	ErrSubmitTimeout = errors.New("submit job timeout")
+	// ErrJobCanceled 任务因为 ctx 被取消而没有完成时上报的错误
+	ErrJobCanceled = errors.New("job canceled")
+	// ErrJobExpired 任务超过截止时间而没有完成时上报的错误
+	ErrJobExpired = errors.New("job deadline exceeded")
+)
+
+// ErrorHandler 任务执行出错时的回调
+type ErrorHandler func(job Job, err error)
+
+// PanicError 任务执行时发生的 panic 转换成的错误
+type PanicError struct {
+	Value interface{} // recover 得到的值
+	Stack []byte      // 发生 panic 时的堆栈
+}
+
+// Error 实现 error 接口
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job panic: %v\n%s", e.Value, e.Stack)
+}
+
+// Unwrap panic 的值是 error 时返回该 error
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package pool
+
+import (
+	"context"
+)
+
+// Future 异步任务的执行结果
+type Future[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// newFuture 创建一个未完成的 Future
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// Done 返回一个通道，任务完成后该通道会被关闭
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 等待任务完成，返回任务的错误；ctx 先结束时返回 ctx 的错误
+func (f *Future[T]) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Result 阻塞直到任务完成，返回任务的结果和错误
+func (f *Future[T]) Result() (T, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// complete 设置任务结果，只能调用一次
+func (f *Future[T]) complete(value T, err error) {
+	f.value = value
+	f.err = err
+	close(f.done)
+}
+
+// funcJob 将带返回值的函数适配为 Job
+type funcJob[T any] struct {
+	ctx    context.Context
+	fn     func(ctx context.Context) (T, error)
+	future *Future[T]
+}
+
+// Do 实现 Job 接口
+func (j *funcJob[T]) Do() {
+	_ = j.do()
+}
+
+// do 执行任务并结束 Future，返回归类后的错误
+func (j *funcJob[T]) do() error {
+	// 任务开始前 ctx 已经结束，则不再执行
+	if err := j.ctx.Err(); err != nil {
+		var zero T
+		j.future.complete(zero, err)
+		return canceledError(err, err)
+	}
+
+	value, err := j.fn(j.ctx)
+	j.future.complete(value, err)
+	if err != nil && j.ctx.Err() != nil {
+		return canceledError(j.ctx.Err(), err)
+	}
+	return err
+}
+
+// returnsError 实现 returner 接口，错误通过 Future 返回
+func (j *funcJob[T]) returnsError() {}
+
+// fail 任务没有正常完成时，以 err 结束 Future
+func (j *funcJob[T]) fail(err error) {
+	var zero T
+	j.future.complete(zero, err)
+}
+
+// Submit 向 worker pool 提交一个带返回值的任务，返回该任务的 Future。
+// 提交失败（ctx 结束或 worker pool 已关闭）时任务不会被执行，Future 返回对应的错误
+func Submit[T any](ctx context.Context, p *WorkerPool, fn func(ctx context.Context) (T, error)) *Future[T] {
+	job := &funcJob[T]{ctx: ctx, fn: fn, future: newFuture[T]()}
+
+	if err := p.SubmitContext(ctx, job); err != nil {
+		job.fail(err)
+	}
+
+	return job.future
+}
+
+// SubmitAndWait 批量提交任务并等待全部完成，结果顺序与 fns 一致。
+// 返回第一个出错任务的错误
+func SubmitAndWait[T any](ctx context.Context, p *WorkerPool, fns ...func(ctx context.Context) (T, error)) ([]T, error) {
+	futures := make([]*Future[T], len(fns))
+	for i, fn := range fns {
+		futures[i] = Submit(ctx, p, fn)
+	}
+
+	var firstErr error
+	results := make([]T, len(fns))
+	for i, future := range futures {
+		value, err := future.Result()
+		results[i] = value
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return results, firstErr
+}
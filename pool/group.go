@@ -0,0 +1,146 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Group 在共享的 worker pool 上执行一组任务并等待全部完成，用法与 errgroup 相同。
+// 与 errgroup 不同，任务由 worker pool 中的 worker 执行，不会创建无限多的 goroutine
+type Group struct {
+	pool   *WorkerPool
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+	sem    chan struct{} // 同时执行的任务数限制，为空时不限制
+
+	limit   *Limiter // 组内任务共享的限速器，为空时不限速
+	errOnce sync.Once
+	err     error
+}
+
+// NewGroup 创建一个在 p 上执行任务的 Group
+func NewGroup(p *WorkerPool) *Group {
+	return &Group{pool: p}
+}
+
+// NewGroupWithContext 创建一个在 p 上执行任务的 Group 和由 ctx 派生的 ctx，
+// 第一个任务返回错误或 Wait 返回时，派生的 ctx 会被取消
+func NewGroupWithContext(ctx context.Context, p *WorkerPool) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{pool: p, cancel: cancel}, ctx
+}
+
+// SetLimit 限制组内同时执行（包括在队列中等待）的任务数，n 小于 0 时不限制。
+// 组内还有任务没有完成时修改限制会 panic
+func (g *Group) SetLimit(n int) {
+	if g.sem != nil && len(g.sem) != 0 {
+		panic(fmt.Errorf("pool: modify limit while %v jobs in the group are still active", len(g.sem)))
+	}
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// SetRateLimit 设置组内任务共享的限速器，需要在调用 Go 之前设置
+func (g *Group) SetRateLimit(l *Limiter) {
+	g.limit = l
+}
+
+// Go 提交一个任务，达到 SetLimit 的限制时阻塞等待组内的任务完成。
+// 第一个返回错误的任务会取消 NewGroupWithContext 返回的 ctx，该错误由 Wait 返回。
+// 任务提交失败（例如 worker pool 已关闭）时，提交的错误视为任务的错误
+func (g *Group) Go(fn func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.submit(fn)
+}
+
+// TryGo 在没有达到 SetLimit 的限制时提交一个任务并返回 true，否则立即返回 false
+func (g *Group) TryGo(fn func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.submit(fn)
+	return true
+}
+
+// Wait 等待组内所有的任务完成，返回第一个任务的错误
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(g.err)
+	}
+	return g.err
+}
+
+// submit 将任务提交到 worker pool
+func (g *Group) submit(fn func() error) {
+	g.wg.Add(1)
+	job := &groupTask{group: g, fn: fn}
+	if err := g.pool.Submit(job); err != nil {
+		job.finish(err)
+	}
+}
+
+// setError 记录第一个错误并取消 ctx
+func (g *Group) setError(err error) {
+	g.errOnce.Do(func() {
+		g.err = err
+		if g.cancel != nil {
+			g.cancel(err)
+		}
+	})
+}
+
+// groupTask Group 中的任务
+type groupTask struct {
+	group *Group
+	fn    func() error
+	once  sync.Once
+}
+
+// Do 实现 Job 接口，发生 panic 时由 worker pool 通过 fail 结束任务
+func (j *groupTask) Do() {
+	_ = j.do()
+}
+
+// do 执行任务并结束任务，返回任务的错误
+func (j *groupTask) do() error {
+	err := j.fn()
+	j.finish(err)
+	return err
+}
+
+// returnsError 实现 returner 接口，错误通过 Group.Wait 返回
+func (j *groupTask) returnsError() {}
+
+// fail 任务没有正常完成时，以 err 结束任务
+func (j *groupTask) fail(err error) {
+	j.finish(err)
+}
+
+// limiter 实现 rateLimited 接口
+func (j *groupTask) limiter() *Limiter {
+	return j.group.limit
+}
+
+// finish 结束任务，只有第一次调用有效
+func (j *groupTask) finish(err error) {
+	j.once.Do(func() {
+		if err != nil {
+			j.group.setError(err)
+		}
+		if j.group.sem != nil {
+			<-j.group.sem
+		}
+		j.group.wg.Done()
+	})
+}
@@ -0,0 +1,157 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	l := NewLimiterWithClock(1, 2, clock)
+
+	if !l.Allow() || !l.Allow() {
+		t.Fatal("Allow() = false; want burst of 2")
+	}
+	if l.Allow() {
+		t.Fatal("Allow() = true; want no tokens left")
+	}
+
+	clock.Advance(time.Second)
+	if !l.Allow() {
+		t.Fatal("Allow() = false after refill")
+	}
+
+	// 没有令牌时 Wait 等待令牌补充
+	done := make(chan error, 1)
+	go func() { done <- l.Wait(context.Background()) }()
+	clock.waitTimers(t, 1)
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Wait() = %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{
+		MaxWorker:  4,
+		QueueSize:  16,
+		RateLimits: map[string]*Limiter{"api": NewLimiter(100, 1)},
+	})
+	p.Start()
+
+	var count int64
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		p.Submit(WithGroup(countJob{count: &count}, "api"))
+	}
+	p.Shutdown(context.Background())
+
+	if count != 6 {
+		t.Fatalf("count = %d; want 6", count)
+	}
+	// 第一个任务使用初始的令牌，其余 5 个任务每 10ms 取得一个令牌
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Fatalf("elapsed = %v; want rate limited to 100/s", elapsed)
+	}
+}
+
+func TestCallerRunsRateLimit(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{
+		MaxWorker:    1,
+		RejectPolicy: CallerRunsPolicy,
+		RateLimiter:  NewLimiter(100, 1),
+	})
+	p.Start()
+	defer p.Stop()
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	p.SubmitContext(context.Background(), jobFunc(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+
+	// worker 被占用，任务在提交的 goroutine 中执行，仍然需要等待令牌
+	var count int64
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		p.Submit(countJob{count: &count})
+	}
+	if count != 3 {
+		t.Fatalf("count = %d; want 3 jobs run by the caller", count)
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Fatalf("elapsed = %v; want rate limited to 100/s", elapsed)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	p := NewWorkerPool(4)
+	p.Start()
+	defer p.Stop()
+
+	errFailed := errors.New("failed")
+	g, ctx := NewGroupWithContext(context.Background(), p)
+	g.SetLimit(2)
+
+	var running, maxRunning, count int64
+	for i := 0; i < 10; i++ {
+		i := i
+		g.Go(func() error {
+			n := atomic.AddInt64(&running, 1)
+			defer atomic.AddInt64(&running, -1)
+			for {
+				m := atomic.LoadInt64(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt64(&maxRunning, m, n) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&count, 1)
+			if i == 5 {
+				return errFailed
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != errFailed {
+		t.Fatalf("Wait() = %v; want %v", err, errFailed)
+	}
+	if count != 10 {
+		t.Fatalf("count = %d; want 10", count)
+	}
+	if maxRunning > 2 {
+		t.Fatalf("max running = %d; want at most 2", maxRunning)
+	}
+	if context.Cause(ctx) != errFailed {
+		t.Fatalf("context.Cause() = %v; want %v", context.Cause(ctx), errFailed)
+	}
+}
+
+func TestGroupPoolClosed(t *testing.T) {
+	p := NewWorkerPool(1)
+	p.Start()
+	p.Stop()
+
+	g := NewGroup(p)
+	g.Go(func() error { return nil })
+	if err := g.Wait(); err != ErrPoolClosed {
+		t.Fatalf("Wait() = %v; want %v", err, ErrPoolClosed)
+	}
+	if g.TryGo(func() error { return nil }) != true {
+		t.Fatal("TryGo() = false without limit")
+	}
+}
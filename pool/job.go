@@ -1,5 +1,45 @@
 package pool
 
+import "time"
+
 type Job interface {
 	Do()
 }
+
+// failer 任务没有正常完成（被丢弃或发生 panic）时需要得到通知的任务
+type failer interface {
+	fail(err error)
+}
+
+// returner 通过 Future 或 Group.Wait 将错误返回给调用方的任务，worker 只统计它返回的错误，不再通过 ErrorHandler 上报
+type returner interface {
+	returnsError()
+}
+
+// wrapper 包装了其他任务的任务
+type wrapper interface {
+	unwrap() Job
+}
+
+// unwrap 返回被包装的原始任务
+func unwrap(job Job) Job {
+	for {
+		w, ok := job.(wrapper)
+		if !ok {
+			return job
+		}
+		job = w.unwrap()
+	}
+}
+
+// task 任务队列中的任务及其调度信息
+type task struct {
+	job       Job
+	priority  Priority
+	submitted time.Time // 进入队列的时间
+}
+
+// newTask 创建一个任务
+func newTask(job Job) task {
+	return task{job: job, priority: priorityOf(job), submitted: time.Now()}
+}
@@ -0,0 +1,127 @@
+package pool
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// lane 串行执行任务的通道，同一个 key 的任务总是进入同一个 lane
+type lane struct {
+	jobs chan Job
+}
+
+// laneJob 在 lane 中排队的任务，执行完成或被丢弃后通知 lane 提交下一个任务
+type laneJob struct {
+	Job
+	done chan struct{}
+	once sync.Once
+}
+
+// Do 执行被包装的任务
+func (j *laneJob) Do() {
+	_ = j.do()
+}
+
+// do 执行被包装的任务，返回任务的错误
+func (j *laneJob) do() error {
+	defer j.finish()
+	return doJob(j.Job)
+}
+
+// Priority 返回被包装任务的优先级
+func (j *laneJob) Priority() Priority {
+	return priorityOf(j.Job)
+}
+
+// fail 通知被包装的任务，并结束 lane 对这个任务的等待
+func (j *laneJob) fail(err error) {
+	defer j.finish()
+	if f, ok := j.Job.(failer); ok {
+		f.fail(err)
+	}
+}
+
+// finish 通知 lane 这个任务已经结束，可以多次调用
+func (j *laneJob) finish() {
+	j.once.Do(func() { close(j.done) })
+}
+
+// unwrap 返回被包装的任务
+func (j *laneJob) unwrap() Job {
+	return j.Job
+}
+
+// SubmitKeyed 按 key 提交一个任务：同一个 key 的任务按提交顺序逐个执行，不同 key 的任务可以并行执行。
+// key 通过哈希分配到 Lanes 个串行通道中的一个，通道已满时阻塞等待
+func (p *WorkerPool) SubmitKeyed(key string, job Job) error {
+	if !p.enter() {
+		return ErrPoolClosed
+	}
+
+	l := p.lane(key)
+	select {
+	case l.jobs <- job:
+		// 任务放入 worker pool 的队列后由 lane 结束这次提交
+		return nil
+	case <-p.abort:
+		p.leave()
+		return ErrPoolClosed
+	}
+}
+
+// lane 返回 key 对应的 lane，第一次使用时创建所有的 lane
+func (p *WorkerPool) lane(key string) *lane {
+	p.lanesOnce.Do(func() {
+		n := p.opts.Lanes
+		if n <= 0 {
+			n = p.opts.MaxWorker
+		}
+		size := p.opts.QueueSize
+		if size <= 0 {
+			size = 1
+		}
+
+		p.lanes = make([]*lane, n)
+		for i := range p.lanes {
+			p.lanes[i] = &lane{jobs: make(chan Job, size)}
+			go p.runLane(p.lanes[i])
+		}
+	})
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return p.lanes[h.Sum32()%uint32(len(p.lanes))]
+}
+
+// runLane 逐个将 lane 中的任务提交到 worker pool，等待上一个任务执行完成后才提交下一个
+func (p *WorkerPool) runLane(l *lane) {
+	for job := range l.jobs {
+		t := newTask(&laneJob{Job: job, done: make(chan struct{})})
+
+		// 已经接受的任务在关闭过程中仍然需要执行，所以这里不检查 Quit
+		select {
+		case p.queues[t.priority] <- t:
+			p.onSubmit(t)
+			p.leave()
+		case <-p.abort:
+			atomic.AddUint64(&p.metrics.dropped, 1)
+			p.discard(t.job, ErrPoolClosed)
+			p.leave()
+			continue
+		}
+
+		select {
+		case <-t.job.(*laneJob).done:
+		case <-p.abort:
+		}
+	}
+}
+
+// closeLanes 关闭所有的 lane，调用时所有的提交都已经结束
+func (p *WorkerPool) closeLanes() {
+	p.lanesOnce.Do(func() {})
+	for _, l := range p.lanes {
+		close(l.jobs)
+	}
+}
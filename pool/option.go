@@ -0,0 +1,30 @@
+package pool
+
+import (
+	"runtime"
+	"time"
+)
+
+// DefaultOptions 默认的 worker pool 选项
+var DefaultOptions = Options{
+	MaxWorker: runtime.NumCPU(),
+}
+
+// Options worker pool 选项
+type Options struct {
+	MaxWorker       int                                         // worker 数量，开启自动伸缩时为最大数量
+	MinWorker       int                                         // 开启自动伸缩时的最小 worker 数量
+	IdleTimeout     time.Duration                               // worker 空闲超过该时间后停止，大于 0 时开启自动伸缩
+	ScaleHandler    func(e ScaleEvent)                          // worker 数量变化时的回调，可选
+	QueueSize       int                                         // 每个优先级的任务队列容量，为 0 时提交任务需要等待空闲的 worker
+	RejectPolicy    RejectPolicy                                // 任务队列已满时 Submit 使用的拒绝策略，为空时阻塞等待
+	PriorityWeights map[Priority]int                            // 每轮调度中各优先级最多执行的任务数，默认为 高:4 普通:2 低:1
+	PriorityLimits  map[Priority]int                            // 各优先级同时执行的任务数上限，没有设置时不限制
+	Lanes           int                                         // SubmitKeyed 使用的串行通道数量，默认与 MaxWorker 相同
+	RateLimiter     *Limiter                                    // 整个 worker pool 的限速器，任务执行前需要取得令牌，为空时不限速
+	RateLimits      map[string]*Limiter                         // 各限速分组的限速器，通过 WithGroup 指定任务所属的分组
+	OnSubmit        func(job Job)                               // 任务进入队列后的回调，可选
+	OnStart         func(job Job, wait time.Duration)           // 任务开始执行时的回调，wait 为在队列中等待的时间，可选
+	OnFinish        func(job Job, run time.Duration, err error) // 任务执行完成后的回调，err 为执行失败的原因，可选
+	ErrorHandler    ErrorHandler                                // 任务执行出错（包括 panic）时的回调，通过 Future、Group 返回给调用方的错误不再回调；为空时通过标准库 log 输出到标准错误
+}
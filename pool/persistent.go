@@ -0,0 +1,188 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 持久化队列的默认值
+const (
+	defaultVisibilityTimeout = 30 * time.Second
+	defaultPollInterval      = time.Second
+)
+
+// Message 持久化队列中的消息
+type Message struct {
+	ID       uint   // 消息编号，由 Store 生成
+	Name     string // 处理该消息的处理器名称
+	Payload  []byte // 消息内容
+	Attempts int    // 已经被取出的次数，包括本次
+}
+
+// Store 持久化队列的存储，pool/store 包提供了基于本地文件和数据库表的实现
+type Store interface {
+	// Enqueue 保存一条消息
+	Enqueue(name string, payload []byte) error
+	// Dequeue 取出一条可见的消息，取出后在 visibility 时间内对其他的 Dequeue 不可见，
+	// 超时没有 Ack 的消息会再次可见。没有可见的消息时返回 nil
+	Dequeue(visibility time.Duration) (*Message, error)
+	// Ack 确认消息已经处理完成，之后不会再被取出
+	Ack(id uint) error
+	// Close 关闭存储
+	Close() error
+}
+
+// MessageHandler 处理持久化队列中的消息，返回错误时消息会在可见性超时后重新处理
+type MessageHandler func(payload []byte) error
+
+// PersistentOptions 持久化队列选项
+type PersistentOptions struct {
+	VisibilityTimeout time.Duration                 // 消息取出后多久没有 Ack 会被重新处理，默认为 30s
+	PollInterval      time.Duration                 // 没有消息时查询存储的间隔，默认为 1s
+	ErrorHandler      func(msg *Message, err error) // 消息处理失败时的回调，为空时通过标准库 log 输出到标准错误
+}
+
+// PersistentQueue 持久化的任务队列：消息先保存到 Store，再取出提交到 worker pool 执行，
+// 处理器执行成功后才 Ack，进程重启或处理失败的消息会在可见性超时后重新处理（至少一次）
+type PersistentQueue struct {
+	pool     *WorkerPool
+	store    Store
+	opts     PersistentOptions
+	mu       sync.RWMutex
+	handlers map[string]MessageHandler
+	notify   chan struct{} // 有新消息时唤醒轮询
+	quit     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewPersistentQueue 创建一个持久化队列，消息在 pool 中处理
+func NewPersistentQueue(pool *WorkerPool, store Store, opts PersistentOptions) *PersistentQueue {
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = defaultVisibilityTimeout
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+
+	return &PersistentQueue{
+		pool:     pool,
+		store:    store,
+		opts:     opts,
+		handlers: make(map[string]MessageHandler),
+		notify:   make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+	}
+}
+
+// Handle 注册名称为 name 的消息处理器，需要在 Start 之前注册
+func (q *PersistentQueue) Handle(name string, handler MessageHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[name] = handler
+}
+
+// Enqueue 保存一条由 name 处理器处理的消息
+func (q *PersistentQueue) Enqueue(name string, payload []byte) error {
+	if err := q.store.Enqueue(name, payload); err != nil {
+		return err
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start 开始从存储中取出消息并提交到 worker pool
+func (q *PersistentQueue) Start() {
+	q.wg.Add(1)
+	go q.poll()
+}
+
+// Stop 停止取出消息，已经提交到 worker pool 的消息不受影响，没有 Ack 的消息会在下次启动后重新处理
+func (q *PersistentQueue) Stop() {
+	q.stopOnce.Do(func() {
+		close(q.quit)
+	})
+	q.wg.Wait()
+}
+
+// poll 循环取出消息并提交到 worker pool，worker pool 的队列已满时阻塞等待
+func (q *PersistentQueue) poll() {
+	defer q.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-q.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		msg, err := q.store.Dequeue(q.opts.VisibilityTimeout)
+		if err != nil {
+			q.reportError(nil, err)
+		}
+
+		if msg == nil {
+			timer := time.NewTimer(q.opts.PollInterval)
+			select {
+			case <-timer.C:
+			case <-q.notify:
+				timer.Stop()
+			case <-q.quit:
+				timer.Stop()
+				return
+			}
+			continue
+		}
+
+		// 提交失败的消息没有 Ack，会在可见性超时后重新处理
+		if err := q.pool.SubmitContext(ctx, &persistentJob{queue: q, msg: msg}); err != nil {
+			return
+		}
+	}
+}
+
+// reportError 通过 ErrorHandler 上报消息处理的错误，没有设置 ErrorHandler 时通过标准库 log 输出到标准错误
+func (q *PersistentQueue) reportError(msg *Message, err error) {
+	if q.opts.ErrorHandler != nil {
+		q.opts.ErrorHandler(msg, err)
+		return
+	}
+	log.Printf("pool: persistent queue error: %v", err)
+}
+
+// persistentJob 处理一条持久化队列中的消息
+type persistentJob struct {
+	queue *PersistentQueue
+	msg   *Message
+}
+
+// Do 执行消息的处理器，成功后 Ack
+func (j *persistentJob) Do() {
+	j.queue.mu.RLock()
+	handler, ok := j.queue.handlers[j.msg.Name]
+	j.queue.mu.RUnlock()
+	if !ok {
+		j.queue.reportError(j.msg, fmt.Errorf("no handler for message %q", j.msg.Name))
+		return
+	}
+
+	if err := handler(j.msg.Payload); err != nil {
+		j.queue.reportError(j.msg, err)
+		return
+	}
+
+	if err := j.queue.store.Ack(j.msg.ID); err != nil {
+		j.queue.reportError(j.msg, err)
+	}
+}
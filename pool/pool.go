@@ -1,53 +1,326 @@
 package pool
 
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
 type WorkerPool struct {
-	workers   chan chan Job
-	jobs      chan Job
+	queues    [priorityLevels]chan task // 按优先级划分的任务队列
 	Quit      chan bool
 	maxWorker int
+	minWorker int
+	opts      Options
+
+	mu         sync.RWMutex
+	started    bool
+	closed     bool
+	stopped    bool           // 任务队列已关闭，不再调整 worker 数量
+	pool       []*Worker      // 运行中的 worker
+	nextID     int            // 上一个 worker 的编号
+	running    sync.WaitGroup // 运行中的 worker goroutine 数
+	submitting sync.WaitGroup // 正在提交中的任务数
+	abort      chan struct{}  // 关闭后放弃尚未执行的任务
+	abortOnce  sync.Once
+	done       chan struct{} // 所有 worker 退出后关闭
+	slotFree   chan struct{} // 有优先级限制的任务执行完成后通知正在调度的 worker
+	sched      chan struct{} // 调度权，同一时刻只有一个 worker 从任务队列中取任务
+	lanes      []*lane       // SubmitKeyed 使用的串行通道
+	lanesOnce  sync.Once
+	metrics    *metrics
+
+	// 以下字段只由持有调度权的 worker 使用
+	credits [priorityLevels]int   // 各优先级在本轮调度中剩余可执行的任务数
+	drained [priorityLevels]bool  // 已关闭且取空的任务队列
+	active  [priorityLevels]int32 // 各优先级正在执行的任务数，任务执行完成时会并发修改
 }
 
 func NewWorkerPool(maxWorker int) *WorkerPool {
+	opts := DefaultOptions
+	opts.MaxWorker = maxWorker
+	return NewWorkerPoolWithOptions(opts)
+}
+
+// NewWorkerPoolWithOptions 根据选项创建一个 worker pool
+func NewWorkerPoolWithOptions(opts Options) *WorkerPool {
+	if opts.MaxWorker <= 0 {
+		opts.MaxWorker = DefaultOptions.MaxWorker
+	}
+
+	if opts.MinWorker < 0 || opts.MinWorker > opts.MaxWorker {
+		opts.MinWorker = opts.MaxWorker
+	}
+
+	if opts.QueueSize < 0 {
+		opts.QueueSize = 0
+	}
+
 	pool := &WorkerPool{
-		workers:   make(chan chan Job, maxWorker),
-		jobs:      make(chan Job),
 		Quit:      make(chan bool),
-		maxWorker: maxWorker,
+		maxWorker: opts.MaxWorker,
+		minWorker: opts.MinWorker,
+		opts:      opts,
+		abort:     make(chan struct{}),
+		slotFree:  make(chan struct{}, 1),
+		sched:     make(chan struct{}, 1),
+		metrics:   newMetrics(),
+		done:      make(chan struct{}),
+	}
+	for i := range pool.queues {
+		pool.queues[i] = make(chan task, opts.QueueSize)
 	}
 	return pool
 }
 
 func (p *WorkerPool) Start() {
-	// 初始化 worker
-	for i := 0; i < p.maxWorker; i++ {
-		worker := NewWorker(p.workers)
-		worker.Start()
+	p.mu.Lock()
+	if p.started || p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.started = true
+
+	// 初始化 worker，开启自动伸缩时只启动最小数量的 worker
+	n := p.maxWorker
+	if p.autoscale() {
+		n = p.minWorker
+	}
+	events := make([]ScaleEvent, 0, n)
+	for i := 0; i < n; i++ {
+		events = append(events, p.startWorkerLocked())
 	}
+	p.mu.Unlock()
 
-	go p.dispatch()
+	p.notifyScale(events...)
 }
 
+// Stop 立即停止 worker pool，放弃尚未执行的任务，不等待正在执行的任务完成
 func (p *WorkerPool) Stop() {
-	close(p.Quit)
-}
-
-func (p *WorkerPool) Submit(job Job) {
-	p.jobs <- job
-}
-
-func (p *WorkerPool) dispatch() {
-	for {
-		select {
-		case job := <-p.jobs:
-			// 获取任意一个空闲的 worker，并将任务分配给它
-			workerJob := <-p.workers
-			workerJob <- job
-		case <-p.Quit:
-			// 收到停止信号后，关闭所有 worker 并退出循环
-			for i := 0; i < p.maxWorker; i++ {
-				p.workers <- nil
-			}
-			return
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = p.Shutdown(ctx)
+}
+
+// Shutdown 优雅关闭 worker pool：不再接受新任务，等待正在执行和已提交的任务完成后停止所有 worker。
+// ctx 结束时放弃尚未执行的任务并返回 ctx 的错误，正在执行的任务会继续执行完成。
+// 没有启动过的 worker pool 没有 worker 执行任务，已提交的任务以 ErrPoolClosed 丢弃
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.Quit)
+		if !p.started {
+			p.abortOnce.Do(func() { close(p.abort) })
+		}
+		go p.close()
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		p.abortOnce.Do(func() { close(p.abort) })
+		return ctx.Err()
+	}
+}
+
+// Submit 提交一个任务，worker pool 已关闭时返回 ErrPoolClosed。
+// 任务队列已满时，按照 RejectPolicy 处理，没有设置 RejectPolicy 时阻塞等待
+func (p *WorkerPool) Submit(job Job) error {
+	if p.opts.RejectPolicy == nil {
+		return p.SubmitContext(context.Background(), job)
+	}
+
+	if !p.enter() {
+		return ErrPoolClosed
+	}
+	defer p.leave()
+
+	t := newTask(job)
+	select {
+	case p.queues[t.priority] <- t:
+		p.onSubmit(t)
+		return nil
+	default:
+		p.grow(1)
+		return p.opts.RejectPolicy.Reject(p, job)
+	}
+}
+
+// TrySubmit 尝试提交一个任务，任务队列已满或 worker pool 已关闭时立即返回 false
+func (p *WorkerPool) TrySubmit(job Job) bool {
+	if !p.enter() {
+		return false
+	}
+	defer p.leave()
+
+	t := newTask(job)
+	select {
+	case p.queues[t.priority] <- t:
+		p.onSubmit(t)
+		return true
+	default:
+		return false
+	}
+}
+
+// SubmitTimeout 提交一个任务，任务队列已满时最多等待 timeout，超时返回 ErrSubmitTimeout
+func (p *WorkerPool) SubmitTimeout(job Job, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := p.SubmitContext(ctx, job)
+	if err == context.DeadlineExceeded {
+		return ErrSubmitTimeout
+	}
+	return err
+}
+
+// SubmitContext 提交一个任务，直到任务进入队列、worker pool 关闭或 ctx 结束
+func (p *WorkerPool) SubmitContext(ctx context.Context, job Job) error {
+	if !p.enter() {
+		return ErrPoolClosed
+	}
+	defer p.leave()
+
+	return p.enqueue(ctx, job)
+}
+
+// enter 登记一次提交，worker pool 已关闭时返回 false。
+// 只有登记过的提交才可以向任务队列发送任务，关闭时会等待所有登记的提交返回后才关闭任务队列
+func (p *WorkerPool) enter() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	p.submitting.Add(1)
+	return true
+}
+
+// leave 结束一次提交
+func (p *WorkerPool) leave() {
+	p.submitting.Done()
+}
+
+// enqueue 将任务放入队列，直到成功、worker pool 关闭或 ctx 结束
+func (p *WorkerPool) enqueue(ctx context.Context, job Job) error {
+	t := newTask(job)
+	select {
+	case p.queues[t.priority] <- t:
+		p.onSubmit(t)
+		return nil
+	default:
+	}
+
+	// 队列已满，尝试扩容后等待
+	p.grow(1)
+	select {
+	case p.queues[t.priority] <- t:
+		p.onSubmit(t)
+		return nil
+	case <-p.Quit:
+		return ErrPoolClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close 等待所有正在提交的任务返回后关闭任务队列，worker 执行完队列中剩余的任务后退出。
+// 放弃剩余任务时，worker 退出后丢弃队列中的任务。所有 worker 退出后 Workers 返回 0
+func (p *WorkerPool) close() {
+	p.submitting.Wait()
+	p.closeLanes()
+
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+
+	for _, queue := range p.queues {
+		close(queue)
+	}
+
+	p.running.Wait()
+	p.mu.Lock()
+	for _, w := range p.pool {
+		w.Stop()
+	}
+	p.pool = nil
+	p.mu.Unlock()
+
+	p.drain()
+	close(p.done)
+}
+
+// drain 丢弃任务队列中剩余的任务，直到队列被关闭
+func (p *WorkerPool) drain() {
+	for _, queue := range p.queues {
+		for t := range queue {
+			atomic.AddUint64(&p.metrics.dropped, 1)
+			p.discard(t.job, ErrPoolClosed)
+		}
+	}
+}
+
+// execute 由 worker 执行分配到的任务
+func (p *WorkerPool) execute(t task) {
+	defer p.release(t)
+
+	// 等待限速器的令牌，期间放弃尚未执行的任务则丢弃该任务
+	if !p.throttle(t.job) {
+		atomic.AddUint64(&p.metrics.dropped, 1)
+		p.discard(t.job, ErrPoolClosed)
+		return
+	}
+
+	p.onStart(t)
+	start := time.Now()
+	err := p.run(t.job)
+	p.onFinish(t, time.Since(start), err)
+}
+
+// run 执行任务，返回并上报任务的错误。任务发生 panic 时转换成 PanicError，worker 继续运行
+func (p *WorkerPool) run(job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+			p.discard(job, err)
+			p.reportError(job, err)
 		}
+	}()
+
+	if err = doJob(job); err != nil {
+		if _, ok := unwrap(job).(returner); !ok {
+			p.reportError(job, err)
+		}
+	}
+	return err
+}
+
+// discard 以 err 结束一个没有正常完成的任务，并通知关心结果的任务
+func (p *WorkerPool) discard(job Job, err error) {
+	if f, ok := job.(failer); ok {
+		f.fail(err)
+	}
+}
+
+// drop 丢弃一个被拒绝的任务，并上报 ErrJobDropped
+func (p *WorkerPool) drop(job Job) {
+	atomic.AddUint64(&p.metrics.dropped, 1)
+	p.discard(job, ErrJobDropped)
+	p.reportError(job, ErrJobDropped)
+}
+
+// reportError 通过 ErrorHandler 上报任务的错误，没有设置 ErrorHandler 时通过标准库 log 输出到标准错误
+func (p *WorkerPool) reportError(job Job, err error) {
+	if p.opts.ErrorHandler != nil {
+		p.opts.ErrorHandler(unwrap(job), err)
+		return
 	}
+	log.Printf("pool: job failed: %v", err)
 }
@@ -0,0 +1,745 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubmitFuture(t *testing.T) {
+	p := NewWorkerPool(2)
+	p.Start()
+	defer p.Stop()
+
+	future := Submit(context.Background(), p, func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+
+	value, err := future.Result()
+	if err != nil || value != 42 {
+		t.Fatalf("Result() = %d, %v; want 42, nil", value, err)
+	}
+}
+
+func TestSubmitAndWait(t *testing.T) {
+	p := NewWorkerPool(4)
+	p.Start()
+	defer p.Stop()
+
+	errFailed := errors.New("failed")
+	fns := make([]func(ctx context.Context) (int, error), 10)
+	for i := range fns {
+		i := i
+		fns[i] = func(ctx context.Context) (int, error) {
+			if i == 7 {
+				return 0, errFailed
+			}
+			return i * i, nil
+		}
+	}
+
+	results, err := SubmitAndWait(context.Background(), p, fns...)
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("err = %v; want %v", err, errFailed)
+	}
+	for i, value := range results {
+		if i != 7 && value != i*i {
+			t.Fatalf("results[%d] = %d; want %d", i, value, i*i)
+		}
+	}
+}
+
+func TestFutureWaitContext(t *testing.T) {
+	p := NewWorkerPool(1)
+	p.Start()
+	defer p.Stop()
+
+	release := make(chan struct{})
+	future := Submit(context.Background(), p, func(ctx context.Context) (struct{}, error) {
+		<-release
+		return struct{}{}, nil
+	})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := future.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+type jobFunc func()
+
+func (f jobFunc) Do() {
+	f()
+}
+
+type countJob struct {
+	count *int64
+	delay time.Duration
+}
+
+func (j countJob) Do() {
+	time.Sleep(j.delay)
+	atomic.AddInt64(j.count, 1)
+}
+
+func TestShutdownDrainsJobs(t *testing.T) {
+	p := NewWorkerPool(4)
+	p.Start()
+
+	var count int64
+	for i := 0; i < 100; i++ {
+		if err := p.Submit(countJob{count: &count, delay: time.Millisecond}); err != nil {
+			t.Fatalf("Submit() = %v", err)
+		}
+	}
+	p.mu.RLock()
+	workers := append([]*Worker(nil), p.pool...)
+	p.mu.RUnlock()
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if count != 100 {
+		t.Fatalf("count = %d; want 100", count)
+	}
+	if err := p.Submit(countJob{count: &count}); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("Submit() after Shutdown = %v; want %v", err, ErrPoolClosed)
+	}
+	for _, worker := range workers {
+		select {
+		case <-worker.done:
+		default:
+			t.Fatalf("worker %d still running after Shutdown", worker.id)
+		}
+	}
+	if stats := p.Stats(); stats.Workers != 0 || stats.Idle != 0 {
+		t.Fatalf("Stats() after Shutdown = %+v; want no workers", stats)
+	}
+}
+
+func TestShutdownNotStarted(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{MaxWorker: 2, QueueSize: 4, ErrorHandler: func(job Job, err error) {}})
+	future := Submit(context.Background(), p, func(ctx context.Context) (int, error) { return 1, nil })
+	var count int64
+	p.SubmitKeyed("key", countJob{count: &count})
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if _, err := future.Result(); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("Result() = %v; want %v", err, ErrPoolClosed)
+	}
+	if count != 0 || p.Stats().Dropped != 2 {
+		t.Fatalf("count = %d, Dropped = %d; want jobs to be dropped", count, p.Stats().Dropped)
+	}
+}
+
+func TestShutdownContextExpired(t *testing.T) {
+	p := NewWorkerPool(1)
+	p.Start()
+
+	release := make(chan struct{})
+	first := Submit(context.Background(), p, func(ctx context.Context) (int, error) {
+		<-release
+		return 1, nil
+	})
+	go Submit(context.Background(), p, func(ctx context.Context) (int, error) { return 2, nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() = %v; want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if value, err := first.Result(); err != nil || value != 1 {
+		t.Fatalf("in-flight Result() = %d, %v; want 1, nil", value, err)
+	}
+	<-p.done
+}
+
+type panicJob struct{}
+
+func (panicJob) Do() {
+	panic("boom")
+}
+
+func TestPanicRecovery(t *testing.T) {
+	reported := make(chan error, 1)
+	p := NewWorkerPoolWithOptions(Options{
+		MaxWorker: 1,
+		ErrorHandler: func(job Job, err error) {
+			reported <- err
+		},
+	})
+	p.Start()
+	defer p.Stop()
+
+	if err := p.Submit(panicJob{}); err != nil {
+		t.Fatalf("Submit() = %v", err)
+	}
+	var panicErr *PanicError
+	if err := <-reported; !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Fatalf("reported error = %v; want PanicError with stack", err)
+	}
+
+	// 唯一的 worker 在 panic 之后仍然可以执行任务
+	future := Submit(context.Background(), p, func(ctx context.Context) (int, error) {
+		panic("future boom")
+	})
+	if _, err := future.Result(); !errors.As(err, &panicErr) || panicErr.Value != "future boom" {
+		t.Fatalf("Result() error = %v; want PanicError", err)
+	}
+	<-reported
+}
+
+// blockPool 创建一个 worker 被占满、队列容量为 queueSize 的 worker pool，调用 release 释放 worker
+func blockPool(t *testing.T, queueSize int, policy RejectPolicy) (p *WorkerPool, release func()) {
+	p = NewWorkerPoolWithOptions(Options{
+		MaxWorker:    1,
+		QueueSize:    queueSize,
+		RejectPolicy: policy,
+		ErrorHandler: func(job Job, err error) {},
+	})
+	p.Start()
+
+	started := make(chan struct{})
+	block := make(chan struct{})
+	go Submit(context.Background(), p, func(ctx context.Context) (int, error) {
+		close(started)
+		<-block
+		return 0, nil
+	})
+	<-started
+
+	for i := 0; i < queueSize; i++ {
+		if err := p.SubmitContext(context.Background(), countJob{count: new(int64)}); err != nil {
+			t.Fatalf("SubmitContext() #%d = %v", i, err)
+		}
+	}
+	return p, func() { close(block) }
+}
+
+func TestTrySubmitAndTimeout(t *testing.T) {
+	p, release := blockPool(t, 2, nil)
+	defer p.Stop()
+	defer release()
+
+	if p.TrySubmit(countJob{count: new(int64)}) {
+		t.Fatal("TrySubmit() on full queue = true; want false")
+	}
+	if err := p.SubmitTimeout(countJob{count: new(int64)}, 10*time.Millisecond); !errors.Is(err, ErrSubmitTimeout) {
+		t.Fatalf("SubmitTimeout() = %v; want %v", err, ErrSubmitTimeout)
+	}
+}
+
+func TestRejectPolicies(t *testing.T) {
+	t.Run("error", func(t *testing.T) {
+		p, release := blockPool(t, 1, ErrorPolicy)
+		defer p.Stop()
+		defer release()
+
+		if err := p.Submit(countJob{count: new(int64)}); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("Submit() = %v; want %v", err, ErrQueueFull)
+		}
+	})
+
+	t.Run("caller runs", func(t *testing.T) {
+		p, release := blockPool(t, 1, CallerRunsPolicy)
+		defer p.Stop()
+
+		var count int64
+		if err := p.Submit(countJob{count: &count}); err != nil {
+			t.Fatalf("Submit() = %v", err)
+		}
+		if atomic.LoadInt64(&count) != 1 {
+			t.Fatal("job was not run by the caller")
+		}
+
+		// 在提交的 goroutine 中执行的任务同样计入统计
+		release()
+		p.Shutdown(context.Background())
+		if stats := p.Stats(); stats.Submitted != 3 || stats.Completed != 3 || stats.Busy != 0 {
+			t.Fatalf("Stats() = %+v; want 3 submitted, 3 completed", stats)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		p, release := blockPool(t, 1, DropPolicy)
+		defer p.Stop()
+		defer release()
+
+		job := &funcJob[int]{ctx: context.Background(), fn: nil, future: newFuture[int]()}
+		if err := p.Submit(job); err != nil {
+			t.Fatalf("Submit() = %v", err)
+		}
+		if _, err := job.future.Result(); !errors.Is(err, ErrJobDropped) {
+			t.Fatalf("dropped job error = %v; want %v", err, ErrJobDropped)
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		p, release := blockPool(t, 1, DropOldestPolicy)
+		defer p.Shutdown(context.Background())
+
+		var count int64
+		if err := p.Submit(countJob{count: &count}); err != nil {
+			t.Fatalf("Submit() = %v", err)
+		}
+		release()
+		p.Shutdown(context.Background())
+		if count != 1 {
+			t.Fatalf("count = %d; want newest job to run", count)
+		}
+	})
+}
+
+func TestResize(t *testing.T) {
+	var events []ScaleEvent
+	p := NewWorkerPoolWithOptions(Options{
+		MaxWorker:    2,
+		ScaleHandler: func(e ScaleEvent) { events = append(events, e) },
+	})
+	p.Start()
+	defer p.Stop()
+
+	if err := p.Resize(4); err != nil {
+		t.Fatalf("Resize(4) = %v", err)
+	}
+	if n := p.Workers(); n != 4 {
+		t.Fatalf("Workers() = %d; want 4", n)
+	}
+	if err := p.Resize(1); err != nil {
+		t.Fatalf("Resize(1) = %v", err)
+	}
+	if n := p.Workers(); n != 1 {
+		t.Fatalf("Workers() = %d; want 1", n)
+	}
+
+	want := []ScaleEvent{
+		{WorkerID: 1, Started: true, Workers: 1},
+		{WorkerID: 2, Started: true, Workers: 2},
+		{WorkerID: 3, Started: true, Workers: 3},
+		{WorkerID: 4, Started: true, Workers: 4},
+		{WorkerID: 4, Started: false, Workers: 3},
+		{WorkerID: 3, Started: false, Workers: 2},
+		{WorkerID: 2, Started: false, Workers: 1},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v; want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("events[%d] = %v; want %v", i, events[i], want[i])
+		}
+	}
+
+	var count int64
+	for i := 0; i < 10; i++ {
+		p.Submit(countJob{count: &count})
+	}
+	p.Shutdown(context.Background())
+	if count != 10 {
+		t.Fatalf("count = %d; want 10", count)
+	}
+}
+
+func TestAutoscale(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{
+		MinWorker:   1,
+		MaxWorker:   4,
+		IdleTimeout: 20 * time.Millisecond,
+	})
+	p.Start()
+	defer p.Stop()
+
+	if n := p.Workers(); n != 1 {
+		t.Fatalf("Workers() after Start = %d; want 1", n)
+	}
+
+	release := make(chan struct{})
+	futures := make([]*Future[int], 4)
+	for i := range futures {
+		futures[i] = Submit(context.Background(), p, func(ctx context.Context) (int, error) {
+			<-release
+			return 0, nil
+		})
+	}
+	// 4 个任务同时阻塞，worker 需要扩容到最大数量
+	deadline := time.Now().Add(time.Second)
+	for p.Workers() != 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := p.Workers(); n != 4 {
+		t.Fatalf("Workers() under load = %d; want 4", n)
+	}
+
+	close(release)
+	for _, future := range futures {
+		future.Result()
+	}
+	deadline = time.Now().Add(time.Second)
+	for p.Workers() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := p.Workers(); n != 1 {
+		t.Fatalf("Workers() after idle = %d; want 1", n)
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{MaxWorker: 1, QueueSize: 16})
+	p.Start()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	p.Submit(jobFunc(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+
+	var order []Priority
+	record := func(priority Priority) Job {
+		return WithPriority(jobFunc(func() { order = append(order, priority) }), priority)
+	}
+
+	// 队列中有 12 个高优先级任务和 1 个低优先级任务
+	for i := 0; i < 12; i++ {
+		p.Submit(record(PriorityHigh))
+	}
+	p.Submit(record(PriorityLow))
+	close(release)
+	p.Shutdown(context.Background())
+
+	if len(order) != 13 {
+		t.Fatalf("order = %v; want 13 jobs", order)
+	}
+	for i := 0; i < 4; i++ {
+		if order[i] != PriorityHigh {
+			t.Fatalf("order = %v; want high priority jobs first", order)
+		}
+	}
+	if order[len(order)-1] == PriorityLow {
+		t.Fatalf("order = %v; low priority job starved", order)
+	}
+}
+
+func TestPriorityLimits(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{
+		MaxWorker:      4,
+		QueueSize:      4,
+		PriorityLimits: map[Priority]int{PriorityLow: 1},
+	})
+	p.Start()
+	defer p.Stop()
+
+	var running, maxRunning int64
+	release := make(chan struct{})
+	futures := make([]*Future[int], 3)
+	for i := range futures {
+		futures[i] = SubmitPriority(context.Background(), p, PriorityLow, func(ctx context.Context) (int, error) {
+			n := atomic.AddInt64(&running, 1)
+			if n > atomic.LoadInt64(&maxRunning) {
+				atomic.StoreInt64(&maxRunning, n)
+			}
+			<-release
+			atomic.AddInt64(&running, -1)
+			return 0, nil
+		})
+	}
+
+	// 低优先级任务达到并发限制后，其他优先级的任务不受影响
+	normal := Submit(context.Background(), p, func(ctx context.Context) (int, error) { return 1, nil })
+	if value, err := normal.Result(); err != nil || value != 1 {
+		t.Fatalf("normal Result() = %d, %v; want 1, nil", value, err)
+	}
+
+	close(release)
+	for _, future := range futures {
+		future.Result()
+	}
+	if maxRunning != 1 {
+		t.Fatalf("max running low priority jobs = %d; want 1", maxRunning)
+	}
+}
+
+func TestSubmitKeyedOrder(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{MaxWorker: 8, QueueSize: 16, Lanes: 4})
+	p.Start()
+
+	const keys, jobs = 10, 100
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	var running, maxRunning int64
+
+	var wg sync.WaitGroup
+	for k := 0; k < keys; k++ {
+		key := fmt.Sprintf("key-%d", k)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < jobs; i++ {
+				i := i
+				err := p.SubmitKeyed(key, jobFunc(func() {
+					n := atomic.AddInt64(&running, 1)
+					for {
+						m := atomic.LoadInt64(&maxRunning)
+						if n <= m || atomic.CompareAndSwapInt64(&maxRunning, m, n) {
+							break
+						}
+					}
+					time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+					atomic.AddInt64(&running, -1)
+
+					mu.Lock()
+					got[key] = append(got[key], i)
+					mu.Unlock()
+				}))
+				if err != nil {
+					t.Errorf("SubmitKeyed() = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	for k := 0; k < keys; k++ {
+		key := fmt.Sprintf("key-%d", k)
+		if len(got[key]) != jobs {
+			t.Fatalf("%s ran %d jobs; want %d", key, len(got[key]), jobs)
+		}
+		for i, v := range got[key] {
+			if v != i {
+				t.Fatalf("%s order = %v; want FIFO", key, got[key])
+			}
+		}
+	}
+	if maxRunning < 2 {
+		t.Fatalf("max running = %d; want different keys to run in parallel", maxRunning)
+	}
+}
+
+func TestSubmitKeyedDropOldest(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{
+		MaxWorker:    1,
+		QueueSize:    1,
+		Lanes:        1,
+		RejectPolicy: DropOldestPolicy,
+		ErrorHandler: func(job Job, err error) {},
+	})
+	p.Start()
+
+	started := make(chan struct{})
+	block := make(chan struct{})
+	if err := p.Submit(jobFunc(func() {
+		close(started)
+		<-block
+	})); err != nil {
+		t.Fatalf("Submit() = %v", err)
+	}
+	<-started
+
+	// 第一个任务进入队列，第二个任务在 lane 中等待第一个任务结束
+	var first, second, count int64
+	if err := p.SubmitKeyed("key", countJob{count: &first}); err != nil {
+		t.Fatalf("SubmitKeyed() = %v", err)
+	}
+	for p.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := p.SubmitKeyed("key", countJob{count: &second}); err != nil {
+		t.Fatalf("SubmitKeyed() = %v", err)
+	}
+
+	// 丢弃队列中的第一个任务后 lane 继续提交第二个任务
+	if err := p.Submit(countJob{count: &count}); err != nil {
+		t.Fatalf("Submit() = %v", err)
+	}
+	close(block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if first != 0 || second != 1 || count != 1 {
+		t.Fatalf("ran first = %d, second = %d, count = %d; want 0, 1, 1", first, second, count)
+	}
+	if s := p.Stats(); s.Dropped != 1 {
+		t.Fatalf("Dropped = %d; want 1", s.Dropped)
+	}
+}
+
+func TestStats(t *testing.T) {
+	var starts, finishes, reported int64
+	p := NewWorkerPoolWithOptions(Options{
+		MaxWorker:    2,
+		ErrorHandler: func(job Job, err error) { atomic.AddInt64(&reported, 1) },
+		OnStart:      func(job Job, wait time.Duration) { atomic.AddInt64(&starts, 1) },
+		OnFinish:     func(job Job, run time.Duration, err error) { atomic.AddInt64(&finishes, 1) },
+	})
+	p.Start()
+	name := fmt.Sprintf("pool_test_stats_%p", p)
+	p.Publish(name)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	p.Submit(jobFunc(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+	if stats := p.Stats(); stats.Workers != 2 || stats.Busy != 1 || stats.Idle != 1 {
+		t.Fatalf("Stats() = %+v; want 2 workers, 1 busy, 1 idle", stats)
+	}
+
+	for i := 0; i < 8; i++ {
+		p.Submit(countJob{count: new(int64), delay: time.Millisecond})
+	}
+	p.Submit(panicJob{})
+	Submit(context.Background(), p, func(ctx context.Context) (int, error) {
+		return 0, errors.New("failed")
+	})
+	close(release)
+	p.Shutdown(context.Background())
+
+	stats := p.Stats()
+	if stats.Submitted != 11 || stats.Completed != 11 || stats.Failed != 2 || stats.Busy != 0 {
+		t.Fatalf("Stats() = %+v; want 11 submitted, 11 completed, 2 failed", stats)
+	}
+	if stats.RunTime.Count != 11 || stats.WaitTime.Count != 11 || stats.RunTime.Mean() < time.Millisecond/2 {
+		t.Fatalf("histograms = %+v, %+v", stats.WaitTime, stats.RunTime)
+	}
+	if starts != 11 || finishes != 11 {
+		t.Fatalf("observer calls = %d starts, %d finishes; want 11", starts, finishes)
+	}
+	// Future 返回的错误只统计不上报，panic 仍然上报
+	if reported != 1 {
+		t.Fatalf("reported = %d; want only the panic to be reported", reported)
+	}
+	if v := expvar.Get(name); v == nil || !strings.Contains(v.String(), `"Completed":11`) {
+		t.Fatalf("expvar = %v", v)
+	}
+}
+
+func TestSubmitRetry(t *testing.T) {
+	p := NewWorkerPool(2)
+	p.Start()
+	defer p.Stop()
+
+	errTransient := errors.New("transient")
+	type deadLetter struct {
+		attempts int
+		err      error
+	}
+	letters := make(chan deadLetter, 1)
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		DeadLetter: func(job RetryableJob, attempts int, err error) {
+			letters <- deadLetter{attempts, err}
+		},
+	}
+
+	done := make(chan int, 1)
+	p.SubmitRetry(RetryableFunc(func(attempt int) error {
+		if attempt < 3 {
+			return errTransient
+		}
+		done <- attempt
+		return nil
+	}), policy)
+	if attempt := <-done; attempt != 3 {
+		t.Fatalf("succeeded on attempt %d; want 3", attempt)
+	}
+
+	p.SubmitRetry(RetryableFunc(func(attempt int) error { return errTransient }), policy)
+	if letter := <-letters; letter.attempts != 3 || !errors.Is(letter.err, errTransient) {
+		t.Fatalf("dead letter = %+v; want 3 attempts", letter)
+	}
+
+	p.SubmitRetry(RetryableFunc(func(attempt int) error { return Permanent(errTransient) }), policy)
+	if letter := <-letters; letter.attempts != 1 || !IsPermanent(letter.err) {
+		t.Fatalf("dead letter = %+v; want 1 attempt with permanent error", letter)
+	}
+}
+
+func TestShutdownWaitsForRetry(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{MaxWorker: 1, ErrorHandler: func(job Job, err error) {}})
+	p.Start()
+
+	failed := make(chan struct{})
+	var attempts int64
+	err := p.SubmitRetry(RetryableFunc(func(attempt int) error {
+		atomic.StoreInt64(&attempts, int64(attempt))
+		if attempt == 1 {
+			close(failed)
+			return errors.New("transient")
+		}
+		return nil
+	}), RetryPolicy{MaxAttempts: 2, InitialBackoff: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("SubmitRetry() = %v", err)
+	}
+	<-failed
+
+	// 优雅关闭等待退避中的重试执行完成
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if n := atomic.LoadInt64(&attempts); n != 2 {
+		t.Fatalf("attempts = %d; want 2", n)
+	}
+
+	// 放弃剩余任务时，等待中的重试以 ErrPoolClosed 进入 DeadLetter
+	p = NewWorkerPoolWithOptions(Options{MaxWorker: 1, ErrorHandler: func(job Job, err error) {}})
+	p.Start()
+	letters := make(chan error, 1)
+	p.SubmitRetry(RetryableFunc(func(attempt int) error {
+		return errors.New("transient")
+	}), RetryPolicy{
+		InitialBackoff: time.Hour,
+		DeadLetter:     func(job RetryableJob, attempts int, err error) { letters <- err },
+	})
+	for p.Stats().Completed != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	p.Stop()
+	if err := <-letters; !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("dead letter = %v; want %v", err, ErrPoolClosed)
+	}
+	<-p.done
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 2}
+	for attempt, want := range []time.Duration{10, 20, 40, 50, 50} {
+		if got := policy.backoff(attempt + 1); got != want*time.Millisecond {
+			t.Errorf("backoff(%d) = %v; want %v", attempt+1, got, want*time.Millisecond)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(1); got < 5*time.Millisecond || got > 15*time.Millisecond {
+			t.Fatalf("backoff(1) with jitter = %v; want within [5ms, 15ms]", got)
+		}
+		if got := policy.backoff(5); got < 25*time.Millisecond || got > 50*time.Millisecond {
+			t.Fatalf("backoff(5) with jitter = %v; want within [25ms, 50ms]", got)
+		}
+	}
+}
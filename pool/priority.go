@@ -0,0 +1,261 @@
+package pool
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Priority 任务优先级，数值越大优先级越高
+type Priority int
+
+const (
+	// PriorityLow 低优先级，用于批量的后台任务
+	PriorityLow Priority = iota
+	// PriorityNormal 普通优先级，没有指定优先级的任务使用该优先级
+	PriorityNormal
+	// PriorityHigh 高优先级，用于对延迟敏感的任务
+	PriorityHigh
+
+	priorityLevels = int(PriorityHigh) + 1
+)
+
+// defaultPriorityWeights 默认每轮调度中各优先级最多执行的任务数
+var defaultPriorityWeights = map[Priority]int{
+	PriorityHigh:   4,
+	PriorityNormal: 2,
+	PriorityLow:    1,
+}
+
+// Prioritized 指定了优先级的任务
+type Prioritized interface {
+	Priority() Priority
+}
+
+// priorityJob 为任务指定优先级的包装
+type priorityJob struct {
+	Job
+	priority Priority
+}
+
+// WithPriority 为任务指定优先级，实现了 Prioritized 接口的任务不需要包装
+func WithPriority(job Job, priority Priority) Job {
+	if j, ok := job.(*priorityJob); ok {
+		job = j.Job
+	}
+	return &priorityJob{Job: job, priority: priority}
+}
+
+// Priority 实现 Prioritized 接口
+func (j *priorityJob) Priority() Priority {
+	return j.priority
+}
+
+// do 执行被包装的任务，返回任务的错误
+func (j *priorityJob) do() error {
+	return doJob(j.Job)
+}
+
+// fail 通知被包装的任务
+func (j *priorityJob) fail(err error) {
+	if f, ok := j.Job.(failer); ok {
+		f.fail(err)
+	}
+}
+
+// unwrap 返回被包装的任务
+func (j *priorityJob) unwrap() Job {
+	return j.Job
+}
+
+// priorityOf 返回任务的优先级，超出范围的优先级按最接近的优先级处理
+func priorityOf(job Job) Priority {
+	j, ok := job.(Prioritized)
+	if !ok {
+		return PriorityNormal
+	}
+
+	switch priority := j.Priority(); {
+	case priority < PriorityLow:
+		return PriorityLow
+	case priority > PriorityHigh:
+		return PriorityHigh
+	default:
+		return priority
+	}
+}
+
+// SubmitPriority 以指定的优先级提交一个带返回值的任务，参见 Submit
+func SubmitPriority[T any](ctx context.Context, p *WorkerPool, priority Priority, fn func(ctx context.Context) (T, error)) *Future[T] {
+	job := &funcJob[T]{ctx: ctx, fn: fn, future: newFuture[T]()}
+
+	if err := p.SubmitContext(ctx, WithPriority(job, priority)); err != nil {
+		job.fail(err)
+	}
+
+	return job.future
+}
+
+// queue 返回任务所属优先级的任务队列
+func (p *WorkerPool) queue(job Job) chan task {
+	return p.queues[priorityOf(job)]
+}
+
+// next 由 worker 调用，按照加权轮询从任务队列中取出下一个任务：
+// 每轮调度中各优先级最多执行 PriorityWeights 个任务，高优先级优先，额度用完或队列为空时轮到低优先级，
+// 所有有任务的优先级额度都用完后开始新的一轮，因此低优先级的任务不会被饿死。
+// 达到 PriorityLimits 并发限制的优先级暂时跳过。
+// 同一时刻只有一个 worker 持有调度权并等待任务，其余的 worker 等待调度权，等待期间都会响应停止信号和空闲超时。
+// worker 需要退出（被停止、空闲超时、放弃剩余任务或所有队列关闭且为空）时返回 false
+func (p *WorkerPool) next(w *Worker) (task, bool) {
+	select {
+	case <-w.quit:
+		return task{}, false
+	default:
+	}
+
+	var idle <-chan time.Time
+	if timeout := p.opts.IdleTimeout; timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		idle = timer.C
+	}
+
+	// 获取调度权
+	for acquired := false; !acquired; {
+		select {
+		case p.sched <- struct{}{}:
+			acquired = true
+		case <-w.quit:
+			return task{}, false
+		case <-p.abort:
+			return task{}, false
+		case <-idle: // 空闲超时后尝试缩容，已达到最小数量时继续等待
+			if p.retire(w) {
+				return task{}, false
+			}
+			idle = nil
+		}
+	}
+	defer func() { <-p.sched }()
+
+	for {
+		select {
+		case <-w.quit:
+			return task{}, false
+		case <-p.abort:
+			return task{}, false
+		default:
+		}
+
+		if t, ok := p.poll(); ok {
+			return t, true
+		}
+
+		// 有额度的优先级都没有任务，开始新的一轮
+		p.resetCredits()
+		if t, ok := p.poll(); ok {
+			return t, true
+		}
+
+		// 没有可以执行的任务，等待新的任务或者有任务执行完成
+		var queues [priorityLevels]chan task
+		drained := true
+		for i, queue := range p.queues {
+			if p.drained[i] {
+				continue
+			}
+			drained = false
+			if !p.limited(Priority(i)) {
+				queues[i] = queue
+			}
+		}
+		if drained {
+			return task{}, false
+		}
+
+		select {
+		case t, ok := <-queues[PriorityHigh]:
+			if ok {
+				return p.take(t), true
+			}
+			p.drained[PriorityHigh] = true
+		case t, ok := <-queues[PriorityNormal]:
+			if ok {
+				return p.take(t), true
+			}
+			p.drained[PriorityNormal] = true
+		case t, ok := <-queues[PriorityLow]:
+			if ok {
+				return p.take(t), true
+			}
+			p.drained[PriorityLow] = true
+		case <-p.slotFree:
+		case <-w.quit:
+			return task{}, false
+		case <-p.abort:
+			return task{}, false
+		case <-idle:
+			if p.retire(w) {
+				return task{}, false
+			}
+			idle = nil
+		}
+	}
+}
+
+// poll 按优先级从高到低，非阻塞地从还有额度且没有达到并发限制的队列中取出一个任务
+func (p *WorkerPool) poll() (task, bool) {
+	for i := priorityLevels - 1; i >= 0; i-- {
+		if p.drained[i] || p.credits[i] <= 0 || p.limited(Priority(i)) {
+			continue
+		}
+
+		select {
+		case t, ok := <-p.queues[i]:
+			if ok {
+				return p.take(t), true
+			}
+			p.drained[i] = true
+		default:
+		}
+	}
+	return task{}, false
+}
+
+// take 记录取出的任务占用的额度和并发数
+func (p *WorkerPool) take(t task) task {
+	if p.credits[t.priority] > 0 {
+		p.credits[t.priority]--
+	}
+	atomic.AddInt32(&p.active[t.priority], 1)
+	return t
+}
+
+// release 任务执行完成或被丢弃后释放占用的并发数
+func (p *WorkerPool) release(t task) {
+	atomic.AddInt32(&p.active[t.priority], -1)
+	if _, ok := p.opts.PriorityLimits[t.priority]; ok {
+		select {
+		case p.slotFree <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// limited 该优先级正在执行的任务是否达到了并发限制
+func (p *WorkerPool) limited(priority Priority) bool {
+	limit, ok := p.opts.PriorityLimits[priority]
+	return ok && limit > 0 && atomic.LoadInt32(&p.active[priority]) >= int32(limit)
+}
+
+// resetCredits 开始新的一轮调度，重置各优先级的额度
+func (p *WorkerPool) resetCredits() {
+	for i := range p.credits {
+		weight, ok := p.opts.PriorityWeights[Priority(i)]
+		if !ok || weight <= 0 {
+			weight = defaultPriorityWeights[Priority(i)]
+		}
+		p.credits[i] = weight
+	}
+}
@@ -0,0 +1,198 @@
+package pool
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter 令牌桶限速器，每秒补充 rate 个令牌，最多积累 burst 个令牌
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  int
+	tokens float64   // 当前的令牌数，有任务预约了尚未补充的令牌时为负数
+	last   time.Time // 上一次补充令牌的时间
+	clock  Clock
+}
+
+// NewLimiter 创建一个限速器，rate 为每秒执行的任务数，burst 为允许突发执行的任务数。
+// rate 小于等于 0 时不限速
+func NewLimiter(rate float64, burst int) *Limiter {
+	return NewLimiterWithClock(rate, burst, RealClock)
+}
+
+// NewLimiterWithClock 使用指定的时钟创建一个限速器
+func NewLimiterWithClock(rate float64, burst int, clock Clock) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   clock.Now(),
+		clock:  clock,
+	}
+}
+
+// Allow 有可用的令牌时取走一个令牌并返回 true，否则立即返回 false
+func (l *Limiter) Allow() bool {
+	if l.rate <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Wait 等待并取走一个令牌，ctx 先结束时返回 ctx 的错误
+func (l *Limiter) Wait(ctx context.Context) error {
+	if !l.wait(ctx.Done()) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// wait 等待并取走一个令牌，done 先关闭时归还预约的令牌并返回 false
+func (l *Limiter) wait(done <-chan struct{}) bool {
+	delay := l.reserve()
+	if delay <= 0 {
+		return true
+	}
+
+	timer := l.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return true
+	case <-done:
+		l.unreserve()
+		return false
+	}
+}
+
+// reserve 预约一个令牌，返回令牌补充到位需要等待的时间
+func (l *Limiter) reserve() time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(-l.tokens / l.rate * float64(time.Second)))
+}
+
+// unreserve 归还一个没有使用的令牌
+func (l *Limiter) unreserve() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens = math.Min(l.tokens+1, float64(l.burst))
+}
+
+// refill 按照经过的时间补充令牌，调用时需要持有锁
+func (l *Limiter) refill() {
+	now := l.clock.Now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(l.tokens+elapsed.Seconds()*l.rate, float64(l.burst))
+		l.last = now
+	}
+}
+
+// grouped 属于某个限速分组的任务
+type grouped interface {
+	group() string
+}
+
+// groupJob 为任务指定限速分组的包装
+type groupJob struct {
+	Job
+	name string
+}
+
+// WithGroup 将任务加入名为 name 的限速分组，执行前需要从 Options.RateLimits 中该分组的限速器取得令牌
+func WithGroup(job Job, name string) Job {
+	return &groupJob{Job: job, name: name}
+}
+
+// group 实现 grouped 接口
+func (j *groupJob) group() string {
+	return j.name
+}
+
+// Priority 实现 Prioritized 接口，返回被包装任务的优先级
+func (j *groupJob) Priority() Priority {
+	return priorityOf(j.Job)
+}
+
+// do 执行被包装的任务，返回任务的错误
+func (j *groupJob) do() error {
+	return doJob(j.Job)
+}
+
+// fail 通知被包装的任务
+func (j *groupJob) fail(err error) {
+	if f, ok := j.Job.(failer); ok {
+		f.fail(err)
+	}
+}
+
+// unwrap 返回被包装的任务
+func (j *groupJob) unwrap() Job {
+	return j.Job
+}
+
+// rateLimited 自带限速器的任务
+type rateLimited interface {
+	limiter() *Limiter
+}
+
+// limiters 返回执行任务前需要取得令牌的限速器：worker pool 的限速器、任务所属分组的限速器和任务自带的限速器
+func (p *WorkerPool) limiters(job Job) []*Limiter {
+	var limiters []*Limiter
+	if p.opts.RateLimiter != nil {
+		limiters = append(limiters, p.opts.RateLimiter)
+	}
+
+	for {
+		if g, ok := job.(grouped); ok {
+			if l := p.opts.RateLimits[g.group()]; l != nil {
+				limiters = append(limiters, l)
+			}
+		}
+		if r, ok := job.(rateLimited); ok {
+			if l := r.limiter(); l != nil {
+				limiters = append(limiters, l)
+			}
+		}
+
+		w, ok := job.(wrapper)
+		if !ok {
+			return limiters
+		}
+		job = w.unwrap()
+	}
+}
+
+// throttle 执行任务前依次从各个限速器取得令牌，worker pool 放弃尚未执行的任务时返回 false
+func (p *WorkerPool) throttle(job Job) bool {
+	for _, l := range p.limiters(job) {
+		if !l.wait(p.abort) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package pool
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// RejectPolicy 任务队列已满时 Submit 使用的拒绝策略
+type RejectPolicy interface {
+	Reject(p *WorkerPool, job Job) error
+}
+
+// RejectPolicyFunc 定义实现 RejectPolicy 接口的函数
+type RejectPolicyFunc func(p *WorkerPool, job Job) error
+
+// Reject 实现 RejectPolicy 接口
+func (f RejectPolicyFunc) Reject(p *WorkerPool, job Job) error {
+	return f(p, job)
+}
+
+var (
+	// CallerRunsPolicy 在提交任务的 goroutine 中直接执行任务，与 worker 一样需要取得限速器的令牌并记录统计
+	CallerRunsPolicy RejectPolicy = RejectPolicyFunc(func(p *WorkerPool, job Job) error {
+		t := newTask(job)
+		p.onSubmit(t)
+		// 不经过调度，只占用并发数而不消耗本轮调度的额度，execute 结束时释放
+		atomic.AddInt32(&p.active[t.priority], 1)
+		p.execute(t)
+		return nil
+	})
+
+	// DropPolicy 丢弃新提交的任务
+	DropPolicy RejectPolicy = RejectPolicyFunc(func(p *WorkerPool, job Job) error {
+		p.drop(job)
+		return nil
+	})
+
+	// DropOldestPolicy 丢弃同一优先级队列中最早的任务，再将新任务放入队列。
+	// 任务队列没有缓冲时，退化为阻塞提交
+	DropOldestPolicy RejectPolicy = RejectPolicyFunc(func(p *WorkerPool, job Job) error {
+		queue := p.queue(job)
+		if cap(queue) == 0 {
+			return p.enqueue(context.Background(), job)
+		}
+
+		t := newTask(job)
+		for {
+			select {
+			case queue <- t:
+				p.onSubmit(t)
+				return nil
+			default:
+			}
+
+			select {
+			case oldest := <-queue:
+				p.drop(oldest.job)
+			default:
+			}
+		}
+	})
+
+	// ErrorPolicy 拒绝新提交的任务并返回 ErrQueueFull
+	ErrorPolicy RejectPolicy = RejectPolicyFunc(func(p *WorkerPool, job Job) error {
+		return ErrQueueFull
+	})
+)
@@ -0,0 +1,215 @@
+package pool
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 重试策略的默认值
+const (
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+	defaultMultiplier     = 2
+)
+
+// RetryableJob 可以重试的任务，attempt 为本次是第几次执行（从 1 开始），执行失败时返回错误
+type RetryableJob interface {
+	Run(attempt int) error
+}
+
+// RetryableFunc 定义实现 RetryableJob 接口的函数
+type RetryableFunc func(attempt int) error
+
+// Run 实现 RetryableJob 接口
+func (f RetryableFunc) Run(attempt int) error {
+	return f(attempt)
+}
+
+// RetryPolicy 任务失败后的重试策略
+type RetryPolicy struct {
+	MaxAttempts    int                                             // 最多执行的次数（包括第一次），默认为 3
+	InitialBackoff time.Duration                                   // 第一次重试前等待的时间，默认为 100ms
+	MaxBackoff     time.Duration                                   // 重试前最多等待的时间，默认为 30s
+	Multiplier     float64                                         // 每次重试等待时间的增长倍数，默认为 2
+	Jitter         float64                                         // 等待时间随机抖动的比例，取值 [0, 1]，为 0 时不抖动
+	Retryable      func(err error) bool                            // 判断错误是否可以重试，为空时除 Permanent 错误外都可以重试
+	DeadLetter     func(job RetryableJob, attempts int, err error) // 重试次数用完、遇到不可重试的错误或任务被丢弃时的回调，可选
+}
+
+// permanentError 不可重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent 将错误标记为不可重试
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断错误是否被标记为不可重试
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// retryable 判断错误是否可以重试
+func (r RetryPolicy) retryable(err error) bool {
+	if IsPermanent(err) {
+		return false
+	}
+	if r.Retryable != nil {
+		return r.Retryable(err)
+	}
+	return true
+}
+
+// backoff 返回第 attempt 次执行失败后，重试前需要等待的时间
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	initial, max, multiplier := r.InitialBackoff, r.MaxBackoff, r.Multiplier
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	if multiplier < 1 {
+		multiplier = defaultMultiplier
+	}
+
+	backoff := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if r.Jitter > 0 {
+		jitter := math.Min(r.Jitter, 1)
+		backoff *= 1 + jitter*(rand.Float64()*2-1)
+	}
+	// 抖动之后再限制上限，保证等待时间不超过 MaxBackoff
+	if backoff > float64(max) {
+		backoff = float64(max)
+	}
+	return time.Duration(backoff)
+}
+
+// maxAttempts 返回最多执行的次数
+func (r RetryPolicy) maxAttempts() int {
+	if r.MaxAttempts <= 0 {
+		return defaultMaxAttempts
+	}
+	return r.MaxAttempts
+}
+
+// retryJob 按照重试策略执行 RetryableJob 的任务
+type retryJob struct {
+	pool    *WorkerPool
+	job     RetryableJob
+	policy  RetryPolicy
+	attempt int
+	once    sync.Once
+}
+
+// SubmitRetry 提交一个可以重试的任务。任务失败后等待退避时间再重新提交到 worker pool，
+// 等待期间不占用 worker。最终失败时调用 policy.DeadLetter。
+// 任务在最终结束前一直算作一次提交，Shutdown 会等待尚未结束的重试；放弃剩余任务时，等待中的重试以 ErrPoolClosed 结束
+func (p *WorkerPool) SubmitRetry(job RetryableJob, policy RetryPolicy) error {
+	if !p.enter() {
+		return ErrPoolClosed
+	}
+
+	j := &retryJob{pool: p, job: job, policy: policy}
+	if err := p.Submit(j); err != nil {
+		j.finish()
+		return err
+	}
+	return nil
+}
+
+// Do 实现 Job 接口
+func (j *retryJob) Do() {
+	_ = j.do()
+}
+
+// do 执行一次任务，返回本次执行的错误，失败时按照重试策略安排下一次执行
+func (j *retryJob) do() error {
+	j.attempt++
+	err := j.job.Run(j.attempt)
+	if err == nil {
+		j.finish()
+		return nil
+	}
+
+	if j.attempt >= j.policy.maxAttempts() || !j.policy.retryable(err) {
+		j.fail(err)
+		return err
+	}
+
+	go j.retry(j.policy.backoff(j.attempt))
+	return err
+}
+
+// retry 等待 backoff 后将任务重新放入队列。任务仍然占用着一次提交，
+// 任务队列在任务结束前不会被关闭，所以这里不检查 Quit
+func (j *retryJob) retry(backoff time.Duration) {
+	p := j.pool
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-p.abort:
+		atomic.AddUint64(&p.metrics.dropped, 1)
+		j.fail(ErrPoolClosed)
+		return
+	}
+
+	t := newTask(j)
+	select {
+	case p.queues[t.priority] <- t:
+		p.onSubmit(t)
+		return
+	default:
+	}
+
+	p.grow(1)
+	select {
+	case p.queues[t.priority] <- t:
+		p.onSubmit(t)
+	case <-p.abort:
+		atomic.AddUint64(&p.metrics.dropped, 1)
+		j.fail(ErrPoolClosed)
+	}
+}
+
+// Priority 返回被包装任务的优先级
+func (j *retryJob) Priority() Priority {
+	if job, ok := j.job.(Prioritized); ok {
+		return job.Priority()
+	}
+	return PriorityNormal
+}
+
+// fail 任务最终失败或被丢弃时调用 DeadLetter
+func (j *retryJob) fail(err error) {
+	defer j.finish()
+	if j.policy.DeadLetter != nil {
+		j.policy.DeadLetter(j.job, j.attempt, err)
+	}
+}
+
+// finish 结束任务占用的提交，只有第一次调用有效
+func (j *retryJob) finish() {
+	j.once.Do(j.pool.leave)
+}
@@ -0,0 +1,143 @@
+package pool
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ScaleEvent worker 数量变化事件
+type ScaleEvent struct {
+	WorkerID int  // 启动或停止的 worker 编号
+	Started  bool // true 表示启动了 worker，false 表示停止了 worker
+	Workers  int  // 变化后的 worker 数量
+}
+
+// Workers 返回当前运行中的 worker 数量
+func (p *WorkerPool) Workers() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.pool)
+}
+
+// Resize 调整 worker 的最大数量。
+// 没有开启自动伸缩时立即启动或停止 worker 使数量等于 n；开启自动伸缩时停止超出 n 的 worker，之后按需扩容到 n。
+// 被停止的 worker 会先执行完当前任务再退出
+func (p *WorkerPool) Resize(n int) error {
+	if n <= 0 {
+		return errors.New("invalid worker number")
+	}
+
+	var events []ScaleEvent
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+
+	p.maxWorker = n
+	if p.minWorker > n {
+		p.minWorker = n
+	}
+
+	if p.started {
+		for len(p.pool) > n {
+			events = append(events, p.stopWorkerLocked(p.pool[len(p.pool)-1]))
+		}
+		for !p.autoscale() && len(p.pool) < n {
+			events = append(events, p.startWorkerLocked())
+		}
+	}
+	p.mu.Unlock()
+
+	p.notifyScale(events...)
+	return nil
+}
+
+// autoscale 是否开启了自动伸缩
+func (p *WorkerPool) autoscale() bool {
+	return p.opts.IdleTimeout > 0
+}
+
+// grow 自动伸缩时，等待执行的任务（队列中的任务加上 pending 个正在等待入队的任务）多于空闲的 worker，
+// 且未达到最大数量，则启动一个新的 worker
+func (p *WorkerPool) grow(pending int) {
+	if !p.autoscale() {
+		return
+	}
+
+	p.mu.Lock()
+	if p.stopped || len(p.pool) >= p.maxWorker || p.queued()+pending <= p.idleLocked() {
+		p.mu.Unlock()
+		return
+	}
+	event := p.startWorkerLocked()
+	p.mu.Unlock()
+
+	p.notifyScale(event)
+}
+
+// retire 自动伸缩时，worker 空闲超时后停止该 worker。
+// worker 数量不会少于最小数量，队列中还有任务时不缩容
+func (p *WorkerPool) retire(w *Worker) bool {
+	p.mu.Lock()
+	if p.stopped || len(p.pool) <= p.minWorker || p.queued() > 0 {
+		p.mu.Unlock()
+		return false
+	}
+	event := p.stopWorkerLocked(w)
+	p.mu.Unlock()
+
+	p.notifyScale(event)
+	return true
+}
+
+// queued 返回队列中等待执行的任务数
+func (p *WorkerPool) queued() int {
+	n := 0
+	for _, queue := range p.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// idleLocked 返回没有在执行任务的 worker 数量，调用时需要持有锁
+func (p *WorkerPool) idleLocked() int {
+	busy := int(atomic.LoadInt64(&p.metrics.busy))
+	if busy >= len(p.pool) {
+		return 0
+	}
+	return len(p.pool) - busy
+}
+
+// startWorkerLocked 启动一个新的 worker，调用时需要持有锁
+func (p *WorkerPool) startWorkerLocked() ScaleEvent {
+	p.nextID++
+	worker := NewWorker(p.nextID, p)
+	worker.Start()
+	p.pool = append(p.pool, worker)
+
+	return ScaleEvent{WorkerID: worker.id, Started: true, Workers: len(p.pool)}
+}
+
+// stopWorkerLocked 停止一个 worker 并将其移出 worker 池，调用时需要持有锁
+func (p *WorkerPool) stopWorkerLocked(w *Worker) ScaleEvent {
+	for i, worker := range p.pool {
+		if worker == w {
+			p.pool = append(p.pool[:i], p.pool[i+1:]...)
+			break
+		}
+	}
+	w.Stop()
+
+	return ScaleEvent{WorkerID: w.id, Started: false, Workers: len(p.pool)}
+}
+
+// notifyScale 通过 ScaleHandler 通知 worker 数量的变化
+func (p *WorkerPool) notifyScale(events ...ScaleEvent) {
+	if p.opts.ScaleHandler == nil {
+		return
+	}
+	for _, event := range events {
+		p.opts.ScaleHandler(event)
+	}
+}
@@ -0,0 +1,244 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSchedulerStopped 调度器已停止后安排任务返回的错误
+var ErrSchedulerStopped = errors.New("scheduler is stopped")
+
+// Scheduler 在 worker pool 上执行延迟任务和周期任务
+type Scheduler struct {
+	pool      *WorkerPool
+	clock     Clock
+	mu        sync.Mutex
+	stopped   bool
+	schedules map[*Schedule]struct{}
+	wg        sync.WaitGroup
+}
+
+// Schedule 已安排的任务，可以通过 Cancel 取消
+type Schedule struct {
+	job        Job
+	next       func(now time.Time) time.Time // 返回下一次执行的时间，返回零值表示不再执行
+	running    int32                         // 上一次执行是否还没有完成
+	skipped    uint64                        // 因为上一次执行还没有完成而跳过的次数
+	mu         sync.Mutex
+	nextRun    time.Time
+	cancel     chan struct{}
+	cancelOnce sync.Once
+	done       chan struct{} // 不再调度后关闭
+}
+
+// NewScheduler 创建一个使用系统时钟的调度器，任务在 pool 中执行
+func NewScheduler(pool *WorkerPool) *Scheduler {
+	return NewSchedulerWithClock(pool, RealClock)
+}
+
+// NewSchedulerWithClock 创建一个使用指定时钟的调度器
+func NewSchedulerWithClock(pool *WorkerPool, clock Clock) *Scheduler {
+	return &Scheduler{
+		pool:      pool,
+		clock:     clock,
+		schedules: make(map[*Schedule]struct{}),
+	}
+}
+
+// SubmitAfter 在 delay 之后提交任务
+func (s *Scheduler) SubmitAfter(delay time.Duration, job Job) (*Schedule, error) {
+	return s.SubmitAt(s.clock.Now().Add(delay), job)
+}
+
+// SubmitAt 在指定的时间提交任务，时间已经过去时立即提交
+func (s *Scheduler) SubmitAt(at time.Time, job Job) (*Schedule, error) {
+	fired := false
+	return s.schedule(job, func(now time.Time) time.Time {
+		if fired {
+			return time.Time{}
+		}
+		fired = true
+		return at
+	})
+}
+
+// Every 每隔 interval 提交一次任务，上一次执行还没有完成时跳过本次执行
+func (s *Scheduler) Every(interval time.Duration, job Job) (*Schedule, error) {
+	if interval <= 0 {
+		return nil, errors.New("invalid schedule interval")
+	}
+	return s.schedule(job, func(now time.Time) time.Time {
+		return now.Add(interval)
+	})
+}
+
+// Cron 按 cron 表达式提交任务，上一次执行还没有完成时跳过本次执行，表达式格式参见 ParseCron
+func (s *Scheduler) Cron(expr string, job Job) (*Schedule, error) {
+	cron, err := ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+	return s.schedule(job, cron.Next)
+}
+
+// Stop 取消所有的任务并等待调度结束，已经提交到 worker pool 的任务不受影响
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	for schedule := range s.schedules {
+		schedule.Cancel()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// schedule 安排一个任务，每个任务由单独的 goroutine 调度
+func (s *Scheduler) schedule(job Job, next func(now time.Time) time.Time) (*Schedule, error) {
+	schedule := &Schedule{
+		job:    job,
+		next:   next,
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return nil, ErrSchedulerStopped
+	}
+	s.schedules[schedule] = struct{}{}
+	s.wg.Add(1)
+	go s.run(schedule)
+
+	return schedule, nil
+}
+
+// run 等待到达执行时间后提交任务，直到不再需要执行或被取消
+func (s *Scheduler) run(schedule *Schedule) {
+	defer s.wg.Done()
+	defer close(schedule.done)
+	defer func() {
+		s.mu.Lock()
+		delete(s.schedules, schedule)
+		s.mu.Unlock()
+	}()
+
+	// worker pool 已满时提交会阻塞，取消任务时需要能够中断提交
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-schedule.cancel:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		now := s.clock.Now()
+		next := schedule.next(now)
+		if next.IsZero() {
+			return
+		}
+		schedule.setNextRun(next)
+
+		timer := s.clock.NewTimer(next.Sub(now))
+		select {
+		case <-timer.C():
+		case <-schedule.cancel:
+			timer.Stop()
+			return
+		}
+
+		if !s.fire(ctx, schedule) {
+			return
+		}
+	}
+}
+
+// fire 将任务提交到 worker pool，任务队列已满时等待到有空位或者 ctx 结束。
+// worker pool 已关闭或者任务被取消时返回 false
+func (s *Scheduler) fire(ctx context.Context, schedule *Schedule) bool {
+	if !atomic.CompareAndSwapInt32(&schedule.running, 0, 1) {
+		atomic.AddUint64(&schedule.skipped, 1)
+		return true
+	}
+
+	err := s.pool.SubmitContext(ctx, &scheduledJob{Job: schedule.job, schedule: schedule})
+	if err != nil {
+		atomic.StoreInt32(&schedule.running, 0)
+		if err == ErrPoolClosed || ctx.Err() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Cancel 取消任务，已经提交到 worker pool 的任务不受影响
+func (s *Schedule) Cancel() {
+	s.cancelOnce.Do(func() {
+		close(s.cancel)
+	})
+}
+
+// Done 返回一个通道，任务不再调度后该通道会被关闭
+func (s *Schedule) Done() <-chan struct{} {
+	return s.done
+}
+
+// Next 返回下一次执行的时间
+func (s *Schedule) Next() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextRun
+}
+
+// Skipped 返回因为上一次执行还没有完成而跳过的次数
+func (s *Schedule) Skipped() uint64 {
+	return atomic.LoadUint64(&s.skipped)
+}
+
+func (s *Schedule) setNextRun(t time.Time) {
+	s.mu.Lock()
+	s.nextRun = t
+	s.mu.Unlock()
+}
+
+// scheduledJob 调度器提交的任务，执行完成后允许下一次执行
+type scheduledJob struct {
+	Job
+	schedule *Schedule
+}
+
+// Do 执行被包装的任务
+func (j *scheduledJob) Do() {
+	_ = j.do()
+}
+
+// do 执行被包装的任务，返回任务的错误
+func (j *scheduledJob) do() error {
+	defer atomic.StoreInt32(&j.schedule.running, 0)
+	return doJob(j.Job)
+}
+
+// Priority 返回被包装任务的优先级
+func (j *scheduledJob) Priority() Priority {
+	return priorityOf(j.Job)
+}
+
+// fail 任务没有执行时允许下一次执行
+func (j *scheduledJob) fail(err error) {
+	atomic.StoreInt32(&j.schedule.running, 0)
+	if f, ok := j.Job.(failer); ok {
+		f.fail(err)
+	}
+}
+
+// unwrap 返回被包装的任务
+func (j *scheduledJob) unwrap() Job {
+	return j.Job
+}
@@ -0,0 +1,222 @@
+package pool
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	when  time.Time
+	c     chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, when: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance 推进时钟，触发到期的定时器
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	timers := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.when.After(c.now) {
+			timers = append(timers, timer)
+		} else {
+			timer.c <- c.now
+		}
+	}
+	c.timers = timers
+}
+
+// waitTimers 等待有 n 个定时器在等待触发
+func (c *fakeClock) waitTimers(t *testing.T, n int) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		count := len(c.timers)
+		c.mu.Unlock()
+		if count >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d timers", n)
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func TestSchedulerSubmitAfter(t *testing.T) {
+	p := NewWorkerPool(2)
+	p.Start()
+	defer p.Stop()
+	clock := newFakeClock(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+	s := NewSchedulerWithClock(p, clock)
+	defer s.Stop()
+
+	ran := make(chan struct{}, 2)
+	schedule, err := s.SubmitAfter(time.Minute, jobFunc(func() { ran <- struct{}{} }))
+	if err != nil {
+		t.Fatalf("SubmitAfter() = %v", err)
+	}
+	canceled, _ := s.SubmitAfter(time.Minute, jobFunc(func() { ran <- struct{}{} }))
+	clock.waitTimers(t, 2)
+	canceled.Cancel()
+	<-canceled.Done()
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-ran:
+		t.Fatal("job ran before its delay")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	<-ran
+	<-schedule.Done()
+	select {
+	case <-ran:
+		t.Fatal("canceled job ran")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestSchedulerStopWhilePoolFull(t *testing.T) {
+	p := NewWorkerPool(1)
+	p.Start()
+	defer p.Stop()
+	clock := newFakeClock(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+	s := NewSchedulerWithClock(p, clock)
+
+	// 唯一的 worker 被占用且队列没有缓冲，到期的任务阻塞在提交中
+	release := make(chan struct{})
+	defer close(release)
+	p.Submit(jobFunc(func() { <-release }))
+	schedule, _ := s.SubmitAfter(time.Minute, jobFunc(func() {}))
+	clock.waitTimers(t, 1)
+	clock.Advance(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop() blocked on a full pool")
+	}
+	<-schedule.Done()
+}
+
+func TestSchedulerEveryPreventsOverlap(t *testing.T) {
+	p := NewWorkerPool(2)
+	p.Start()
+	defer p.Stop()
+	clock := newFakeClock(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+	s := NewSchedulerWithClock(p, clock)
+	defer s.Stop()
+
+	var runs int64
+	release := make(chan struct{})
+	schedule, err := s.Every(time.Minute, jobFunc(func() {
+		atomic.AddInt64(&runs, 1)
+		<-release
+	}))
+	if err != nil {
+		t.Fatalf("Every() = %v", err)
+	}
+
+	// 第一次执行一直没有完成，后面两次都会被跳过
+	for i := 0; i < 3; i++ {
+		clock.waitTimers(t, 1)
+		clock.Advance(time.Minute)
+	}
+	clock.waitTimers(t, 1)
+	if n := atomic.LoadInt64(&runs); n != 1 || schedule.Skipped() != 2 {
+		t.Fatalf("runs = %d, skipped = %d; want 1, 2", n, schedule.Skipped())
+	}
+
+	close(release)
+	for atomic.LoadInt32(&schedule.running) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+	clock.waitTimers(t, 1)
+	for atomic.LoadInt64(&runs) != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if want := clock.Now().Add(time.Minute); !schedule.Next().Equal(want) {
+		t.Fatalf("Next() = %v; want %v", schedule.Next(), want)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 9-17 * * 1-5", time.Date(2023, 1, 6, 17, 50, 0, 0, time.UTC), time.Date(2023, 1, 9, 9, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2023, 1, 1, 2, 30, 0, 0, time.UTC), time.Date(2023, 1, 2, 2, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * 0", time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 8, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2023, 1, 1, 5, 1, 0, 0, time.UTC), time.Date(2023, 1, 1, 6, 0, 0, 0, time.UTC)},
+		{"5,10 * * * 7", time.Date(2023, 1, 1, 0, 7, 0, 0, time.UTC), time.Date(2023, 1, 1, 0, 10, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) = %v", tt.expr, err)
+		}
+		if got := cron.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next(%v) = %v; want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) = nil error; want error", expr)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package pool
+
+import (
+	"errors"
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// histogramBounds 耗时直方图各个桶的上限
+var histogramBounds = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Stats worker pool 的运行状态快照
+type Stats struct {
+	Workers   int       // 运行中的 worker 数量
+	Busy      int       // 正在执行任务的 worker 数量
+	Idle      int       // 空闲的 worker 数量
+	Queued    int       // 队列中等待执行的任务数
+	Submitted uint64    // 已提交的任务数
+	Completed uint64    // 已执行完成的任务数，包括失败、取消和超时的任务
+	Failed    uint64    // 执行失败的任务数
+	Canceled  uint64    // 因为 ctx 被取消而没有完成的任务数
+	Expired   uint64    // 超过截止时间而没有完成的任务数
+	Dropped   uint64    // 被丢弃而没有执行的任务数
+	WaitTime  Histogram // 任务在队列中等待的时间
+	RunTime   Histogram // 任务执行的时间
+}
+
+// Histogram 耗时直方图
+type Histogram struct {
+	Count   uint64            // 记录的次数
+	Sum     time.Duration     // 记录的总耗时
+	Buckets []HistogramBucket // 各个桶的计数，最后一个桶没有上限
+}
+
+// HistogramBucket 直方图中的一个桶
+type HistogramBucket struct {
+	UpperBound time.Duration // 桶的上限（包含），为 0 时表示没有上限
+	Count      uint64        // 耗时落在该桶中的次数
+}
+
+// Mean 返回平均耗时
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// histogram 并发安全的耗时直方图
+type histogram struct {
+	count   uint64
+	sum     int64
+	buckets []uint64
+}
+
+func newHistogram() *histogram {
+	return &histogram{buckets: make([]uint64, len(histogramBounds)+1)}
+}
+
+// observe 记录一次耗时
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(histogramBounds) && d > histogramBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&h.buckets[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+	atomic.AddUint64(&h.count, 1)
+}
+
+// snapshot 返回直方图的快照
+func (h *histogram) snapshot() Histogram {
+	snapshot := Histogram{
+		Count:   atomic.LoadUint64(&h.count),
+		Sum:     time.Duration(atomic.LoadInt64(&h.sum)),
+		Buckets: make([]HistogramBucket, len(h.buckets)),
+	}
+	for i := range h.buckets {
+		snapshot.Buckets[i].Count = atomic.LoadUint64(&h.buckets[i])
+		if i < len(histogramBounds) {
+			snapshot.Buckets[i].UpperBound = histogramBounds[i]
+		}
+	}
+	return snapshot
+}
+
+// metrics worker pool 的运行指标
+type metrics struct {
+	busy      int64
+	submitted uint64
+	completed uint64
+	failed    uint64
+	canceled  uint64
+	expired   uint64
+	dropped   uint64
+	waitTime  *histogram
+	runTime   *histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{waitTime: newHistogram(), runTime: newHistogram()}
+}
+
+// Stats 返回 worker pool 当前的运行状态
+func (p *WorkerPool) Stats() Stats {
+	stats := Stats{
+		Workers:   p.Workers(),
+		Busy:      int(atomic.LoadInt64(&p.metrics.busy)),
+		Submitted: atomic.LoadUint64(&p.metrics.submitted),
+		Completed: atomic.LoadUint64(&p.metrics.completed),
+		Failed:    atomic.LoadUint64(&p.metrics.failed),
+		Canceled:  atomic.LoadUint64(&p.metrics.canceled),
+		Expired:   atomic.LoadUint64(&p.metrics.expired),
+		Dropped:   atomic.LoadUint64(&p.metrics.dropped),
+		WaitTime:  p.metrics.waitTime.snapshot(),
+		RunTime:   p.metrics.runTime.snapshot(),
+	}
+	if stats.Busy < stats.Workers {
+		stats.Idle = stats.Workers - stats.Busy
+	}
+	stats.Queued = p.queued()
+	return stats
+}
+
+// Publish 将 worker pool 的运行状态以 name 发布到 expvar，name 重复时会 panic
+func (p *WorkerPool) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return p.Stats()
+	}))
+}
+
+// onSubmit 任务进入队列后记录并回调 OnSubmit，等待的任务多于空闲的 worker 时尝试扩容
+func (p *WorkerPool) onSubmit(t task) {
+	atomic.AddUint64(&p.metrics.submitted, 1)
+	if p.opts.OnSubmit != nil {
+		p.opts.OnSubmit(unwrap(t.job))
+	}
+	p.grow(0)
+}
+
+// onStart worker 开始执行任务时记录并回调 OnStart
+func (p *WorkerPool) onStart(t task) {
+	wait := time.Since(t.submitted)
+	atomic.AddInt64(&p.metrics.busy, 1)
+	p.metrics.waitTime.observe(wait)
+	if p.opts.OnStart != nil {
+		p.opts.OnStart(unwrap(t.job), wait)
+	}
+}
+
+// onFinish 任务执行完成后记录并回调 OnFinish
+func (p *WorkerPool) onFinish(t task, run time.Duration, err error) {
+	atomic.AddInt64(&p.metrics.busy, -1)
+	atomic.AddUint64(&p.metrics.completed, 1)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrJobCanceled):
+		atomic.AddUint64(&p.metrics.canceled, 1)
+	case errors.Is(err, ErrJobExpired):
+		atomic.AddUint64(&p.metrics.expired, 1)
+	default:
+		atomic.AddUint64(&p.metrics.failed, 1)
+	}
+	p.metrics.runTime.observe(run)
+	if p.opts.OnFinish != nil {
+		p.opts.OnFinish(unwrap(t.job), run, err)
+	}
+}
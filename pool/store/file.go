@@ -0,0 +1,250 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dyouwan/utility/pool"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileRecord 追加日志中的一条记录
+type fileRecord struct {
+	Op      string `json:"op"` // enqueue 或 ack
+	ID      uint   `json:"id"`
+	Name    string `json:"name,omitempty"`
+	Payload []byte `json:"payload,omitempty"`
+}
+
+// FileStore 基于本地追加日志文件的 Store。
+// 每次 Enqueue 和 Ack 都会追加一条记录并刷入磁盘，打开时回放日志恢复未 Ack 的消息，
+// 可见性超时和取出次数只保存在内存中，重启后所有未 Ack 的消息立即可见
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	nextID  uint
+	pending map[uint]*fileMessage
+	order   []uint // 按 Enqueue 顺序排列的消息编号，可能包含已经 Ack 的编号
+	acked   int    // 日志中已经 Ack 的记录数，用于判断是否需要压缩
+	closed  bool
+}
+
+// fileMessage 内存中未 Ack 的消息
+type fileMessage struct {
+	msg            pool.Message
+	invisibleUntil time.Time
+}
+
+// defaultCompactThreshold 日志中已经 Ack 的消息超过该数量时压缩日志
+const defaultCompactThreshold = 1024
+
+// OpenFileStore 打开或创建 path 对应的日志文件
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		path:    path,
+		pending: make(map[uint]*fileMessage),
+	}
+
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay 回放日志，恢复未 Ack 的消息
+func (s *FileStore) replay() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var record fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// 最后一条记录可能因为进程退出而写入不完整，忽略
+			continue
+		}
+
+		switch record.Op {
+		case "enqueue":
+			s.pending[record.ID] = &fileMessage{msg: pool.Message{ID: record.ID, Name: record.Name, Payload: record.Payload}}
+			s.order = append(s.order, record.ID)
+		case "ack":
+			delete(s.pending, record.ID)
+		}
+		if record.ID > s.nextID {
+			s.nextID = record.ID
+		}
+	}
+	return scanner.Err()
+}
+
+// compact 只保留未 Ack 的消息重写日志文件
+func (s *FileStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	order := s.order[:0]
+	writer := bufio.NewWriter(tmp)
+	for _, id := range s.order {
+		m, ok := s.pending[id]
+		if !ok {
+			continue
+		}
+		order = append(order, id)
+		if err := writeRecord(writer, fileRecord{Op: "enqueue", ID: id, Name: m.msg.Name, Payload: m.msg.Payload}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+
+	s.file = file
+	s.order = order
+	s.acked = 0
+	return nil
+}
+
+// writeRecord 写入一条 JSON 记录
+func writeRecord(writer *bufio.Writer, record fileRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+// append 追加一条记录并刷入磁盘
+func (s *FileStore) append(record fileRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Enqueue 实现 Store 接口
+func (s *FileStore) Enqueue(name string, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("file store is closed")
+	}
+
+	id := s.nextID + 1
+	if err := s.append(fileRecord{Op: "enqueue", ID: id, Name: name, Payload: payload}); err != nil {
+		return err
+	}
+
+	s.nextID = id
+	s.pending[id] = &fileMessage{msg: pool.Message{ID: id, Name: name, Payload: payload}}
+	s.order = append(s.order, id)
+	return nil
+}
+
+// Dequeue 实现 Store 接口
+func (s *FileStore) Dequeue(visibility time.Duration) (*pool.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errors.New("file store is closed")
+	}
+
+	now := time.Now()
+	for _, id := range s.order {
+		m, ok := s.pending[id]
+		if !ok || now.Before(m.invisibleUntil) {
+			continue
+		}
+
+		m.invisibleUntil = now.Add(visibility)
+		m.msg.Attempts++
+		msg := m.msg
+		return &msg, nil
+	}
+	return nil, nil
+}
+
+// Ack 实现 Store 接口
+func (s *FileStore) Ack(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("file store is closed")
+	}
+	if _, ok := s.pending[id]; !ok {
+		return fmt.Errorf("message %d not found", id)
+	}
+
+	if err := s.append(fileRecord{Op: "ack", ID: id}); err != nil {
+		return err
+	}
+	delete(s.pending, id)
+
+	s.acked++
+	if s.acked >= defaultCompactThreshold {
+		return s.compact()
+	}
+	return nil
+}
+
+// Len 返回未 Ack 的消息数量
+func (s *FileStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Close 实现 Store 接口
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.file.Close()
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"errors"
+	"github.com/dyouwan/utility/pool"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+
+	for _, payload := range []string{"a", "b", "c"} {
+		if err := store.Enqueue("test", []byte(payload)); err != nil {
+			t.Fatalf("Enqueue() = %v", err)
+		}
+	}
+
+	msg, _ := store.Dequeue(20 * time.Millisecond)
+	if msg == nil || string(msg.Payload) != "a" || msg.Attempts != 1 {
+		t.Fatalf("Dequeue() = %+v; want a", msg)
+	}
+	// 没有 Ack 的消息在可见性超时前不会被再次取出
+	if next, _ := store.Dequeue(time.Minute); next == nil || string(next.Payload) != "b" {
+		t.Fatalf("Dequeue() = %+v; want b", next)
+	}
+	if err := store.Ack(msg.ID); err != nil {
+		t.Fatalf("Ack() = %v", err)
+	}
+	store.Close()
+
+	// 重新打开后恢复未 Ack 的消息，所有消息立即可见
+	store, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+	defer store.Close()
+	if n := store.Len(); n != 2 {
+		t.Fatalf("Len() after reopen = %d; want 2", n)
+	}
+	if msg, _ := store.Dequeue(time.Minute); msg == nil || string(msg.Payload) != "b" {
+		t.Fatalf("Dequeue() after reopen = %+v; want b", msg)
+	}
+	if err := store.Enqueue("test", []byte("d")); err != nil {
+		t.Fatalf("Enqueue() = %v", err)
+	}
+	if msg, _ := store.Dequeue(time.Minute); msg == nil || string(msg.Payload) != "c" || msg.ID != 3 {
+		t.Fatalf("Dequeue() = %+v; want c", msg)
+	}
+	if msg, _ := store.Dequeue(time.Minute); msg == nil || string(msg.Payload) != "d" || msg.ID != 4 {
+		t.Fatalf("Dequeue() = %+v; want d with a new id", msg)
+	}
+}
+
+func TestPersistentQueue(t *testing.T) {
+	p := pool.NewWorkerPool(2)
+	p.Start()
+	defer p.Stop()
+
+	path := filepath.Join(t.TempDir(), "queue.log")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+	defer store.Close()
+
+	queue := pool.NewPersistentQueue(p, store, pool.PersistentOptions{
+		VisibilityTimeout: 20 * time.Millisecond,
+		PollInterval:      5 * time.Millisecond,
+		ErrorHandler:      func(msg *pool.Message, err error) {},
+	})
+
+	// 第一次处理失败，消息在可见性超时后重新处理
+	var calls int64
+	done := make(chan string, 1)
+	queue.Handle("email", func(payload []byte) error {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			return errors.New("smtp timeout")
+		}
+		done <- string(payload)
+		return nil
+	})
+	queue.Start()
+	defer queue.Stop()
+
+	if err := queue.Enqueue("email", []byte("hello")); err != nil {
+		t.Fatalf("Enqueue() = %v", err)
+	}
+	select {
+	case payload := <-done:
+		if payload != "hello" {
+			t.Fatalf("payload = %q; want hello", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message was not redelivered")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for store.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := store.Len(); n != 0 {
+		t.Fatalf("Len() = %d; want message to be acked", n)
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dyouwan/utility/database"
+	"github.com/dyouwan/utility/pool"
+	"gorm.io/gorm"
+	"time"
+)
+
+// JobModel 持久化队列中的一条消息
+type JobModel struct {
+	gorm.Model
+	Name      string    `gorm:"type:varchar(255);not null;comment:处理器名称"`
+	Payload   []byte    `gorm:"type:longblob;comment:消息内容"`
+	Attempts  int       `gorm:"not null;default:0;comment:取出次数"`
+	VisibleAt time.Time `gorm:"index;not null;comment:可见时间"`
+}
+
+func (JobModel) TableName() string {
+	return "pool_job"
+}
+
+// GormStore 基于数据库表的 Store，Ack 后的消息被软删除
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore 使用已有的数据库连接创建 Store，会自动创建 pool_job 表
+func NewGormStore(conn *database.Connect) (*GormStore, error) {
+	if conn == nil || conn.DB == nil {
+		return nil, errors.New("database connection is already closed")
+	}
+
+	if err := conn.DB.AutoMigrate(&JobModel{}); err != nil {
+		return nil, fmt.Errorf("an error occurred while creating the pool_job table: %s", err)
+	}
+	return &GormStore{db: conn.DB}, nil
+}
+
+// Enqueue 实现 Store 接口
+func (s *GormStore) Enqueue(name string, payload []byte) error {
+	return s.db.Create(&JobModel{Name: name, Payload: payload, VisibleAt: time.Now()}).Error
+}
+
+// Dequeue 实现 Store 接口。
+// 先查询最早的可见消息，再以查询到的可见时间为条件更新，更新成功才算取出，多个进程同时取出时不会重复
+func (s *GormStore) Dequeue(visibility time.Duration) (*pool.Message, error) {
+	for {
+		now := time.Now()
+
+		var job JobModel
+		err := s.db.Where("visible_at <= ?", now).Order("id").First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := s.db.Model(&JobModel{}).
+			Where("id = ? AND visible_at = ?", job.ID, job.VisibleAt).
+			Updates(map[string]interface{}{
+				"visible_at": now.Add(visibility),
+				"attempts":   gorm.Expr("attempts + 1"),
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return &pool.Message{ID: job.ID, Name: job.Name, Payload: job.Payload, Attempts: job.Attempts + 1}, nil
+		}
+		// 已经被其他消费者取出，重新查询
+	}
+}
+
+// Ack 实现 Store 接口
+func (s *GormStore) Ack(id uint) error {
+	return s.db.Delete(&JobModel{}, id).Error
+}
+
+// Close 实现 Store 接口，数据库连接由调用方关闭
+func (s *GormStore) Close() error {
+	return nil
+}
@@ -0,0 +1,175 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitTimeout 在 timeout 内等待 ch 关闭，超时说明发生了死锁
+func waitTimeout(t *testing.T, ch <-chan struct{}, timeout time.Duration, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(timeout):
+		t.Fatalf("%s did not finish within %v", what, timeout)
+	}
+}
+
+func TestStressSubmitResizeShutdown(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{
+		MaxWorker:      8,
+		MinWorker:      1,
+		IdleTimeout:    time.Millisecond,
+		QueueSize:      4,
+		PriorityLimits: map[Priority]int{PriorityLow: 2},
+		ErrorHandler:   func(job Job, err error) {},
+	})
+	p.Start()
+
+	var accepted, executed int64
+	job := func(priority Priority) Job {
+		return WithPriority(jobFunc(func() { atomic.AddInt64(&executed, 1) }), priority)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				priority := Priority(i % priorityLevels)
+				var err error
+				switch i % 4 {
+				case 0:
+					err = p.Submit(job(priority))
+				case 1:
+					if !p.TrySubmit(job(priority)) {
+						continue
+					}
+				case 2:
+					err = p.SubmitTimeout(job(priority), time.Millisecond)
+				case 3:
+					err = p.SubmitKeyed(fmt.Sprint(g), job(priority))
+				}
+				if err == nil {
+					atomic.AddInt64(&accepted, 1)
+				}
+			}
+		}(g)
+	}
+
+	stopResize := make(chan struct{})
+	resized := make(chan struct{})
+	go func() {
+		defer close(resized)
+		for i := 0; ; i++ {
+			select {
+			case <-stopResize:
+				return
+			default:
+			}
+			p.Resize(i%8 + 1)
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+
+	wg.Wait()
+	close(stopResize)
+	<-resized
+
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		if err := p.Shutdown(context.Background()); err != nil {
+			t.Errorf("Shutdown() = %v", err)
+		}
+	}()
+	waitTimeout(t, shutdown, 10*time.Second, "Shutdown")
+
+	if accepted != executed {
+		t.Fatalf("executed = %d; want %d accepted jobs", executed, accepted)
+	}
+}
+
+func TestStressShutdownAbort(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{MaxWorker: 4, QueueSize: 64})
+	p.Start()
+
+	var futures []*Future[int]
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				future := Submit(context.Background(), p, func(ctx context.Context) (int, error) {
+					time.Sleep(50 * time.Microsecond)
+					return 1, nil
+				})
+				mu.Lock()
+				futures = append(futures, future)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	p.Shutdown(ctx)
+	wg.Wait()
+	waitTimeout(t, p.done, 10*time.Second, "worker pool")
+
+	// 每个任务要么被执行，要么以错误结束，不会一直挂起
+	for _, future := range futures {
+		waitTimeout(t, future.Done(), time.Second, "future")
+	}
+}
+
+func TestStopObservedWhileIdle(t *testing.T) {
+	p := NewWorkerPool(4)
+	p.Start()
+	defer p.Stop()
+
+	p.mu.RLock()
+	workers := append([]*Worker(nil), p.pool...)
+	p.mu.RUnlock()
+
+	// 空闲的 worker 不论是否持有调度权，都能及时响应停止信号
+	if err := p.Resize(1); err != nil {
+		t.Fatalf("Resize() = %v", err)
+	}
+	for _, w := range workers[1:] {
+		waitTimeout(t, w.done, time.Second, fmt.Sprintf("worker %d", w.ID()))
+	}
+}
+
+func TestNoHeadOfLineBlocking(t *testing.T) {
+	p := NewWorkerPoolWithOptions(Options{
+		MaxWorker:      2,
+		QueueSize:      4,
+		PriorityLimits: map[Priority]int{PriorityLow: 1},
+	})
+	p.Start()
+	defer p.Stop()
+
+	block := make(chan struct{})
+	defer close(block)
+	started := make(chan struct{})
+	p.Submit(WithPriority(jobFunc(func() {
+		close(started)
+		<-block
+	}), PriorityLow))
+	<-started
+
+	// 低优先级达到并发限制后，后续的低优先级任务不能阻塞其它任务
+	p.Submit(WithPriority(jobFunc(func() { <-block }), PriorityLow))
+	done := make(chan struct{})
+	p.Submit(WithPriority(jobFunc(func() { close(done) }), PriorityHigh))
+	waitTimeout(t, done, time.Second, "high priority job")
+}
@@ -1,41 +1,50 @@
 package pool
 
 import (
-	"math/rand"
+	"sync"
 )
 
 type Worker struct {
 	id       int
-	jobQueue chan Job
-	workers  chan chan Job
+	pool     *WorkerPool
 	quit     chan bool
+	stopOnce sync.Once
+	done     chan struct{} // worker 退出后关闭
 }
 
-func NewWorker(workers chan chan Job) *Worker {
+func NewWorker(id int, pool *WorkerPool) *Worker {
 	return &Worker{
-		id:       rand.Int(),
-		jobQueue: make(chan Job),
-		workers:  workers,
-		quit:     make(chan bool),
+		id:   id,
+		pool: pool,
+		quit: make(chan bool),
+		done: make(chan struct{}),
 	}
 }
 
+// ID 返回 worker 的编号，同一个 worker pool 中按启动顺序从 1 开始递增
+func (w *Worker) ID() int {
+	return w.id
+}
+
+// Start 启动 worker，worker 直接从任务队列中取出任务执行，直到被停止或任务队列关闭且为空
 func (w *Worker) Start() {
+	w.pool.running.Add(1)
 	go func() {
+		defer w.pool.running.Done()
+		defer close(w.done)
 		for {
-			// 将自己注册到 worker 池中
-			w.workers <- w.jobQueue
-
-			select {
-			case job := <-w.jobQueue: // 收到任务后执行，并通知任务完成
-				job.Do()
-			case <-w.quit: // 收到停止信号后，退出循环并关闭该 worker
+			t, ok := w.pool.next(w)
+			if !ok {
 				return
 			}
+			w.pool.execute(t)
 		}
 	}()
 }
 
+// Stop 通知 worker 退出，正在执行的任务会继续执行完成
 func (w *Worker) Stop() {
-	w.quit <- true
+	w.stopOnce.Do(func() {
+		close(w.quit)
+	})
 }
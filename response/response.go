@@ -23,3 +23,11 @@ func Error(w http.ResponseWriter, err string, code int) {
 	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(map[string]string{"error": err})
 }
+
+// ErrorWithRequestID produces a JSON error response that also carries the request ID:
+// {"error":"some error message","request_id":"..."}
+func ErrorWithRequestID(w http.ResponseWriter, err string, code int, requestID string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err, "request_id": requestID})
+}
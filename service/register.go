@@ -0,0 +1,14 @@
+package service
+
+import (
+	"github.com/dyouwan/utility/pipeline"
+	"github.com/gorilla/mux"
+)
+
+// RegisterRoutes 以服务的路由前缀在 router 上创建路由分组并注册服务的所有路由，
+// middlewares 作用于服务的所有路由，只需要添加一次。服务实现 RegisterRoutes 方法时可以直接调用
+func RegisterRoutes(router *mux.Router, service BaseServiceInterface, middlewares ...pipeline.Handler) *pipeline.RouteGroup {
+	group := pipeline.Group(router, service.GetRoutePrefix(), middlewares...)
+	group.AddRoutes(service.GetRoutes())
+	return group
+}